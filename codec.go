@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+)
+
+// 内置codec名称
+const (
+	CodecJSON = "json"
+	CodecYAML = "yaml"
+	CodecTOML = "toml"
+)
+
+// Codec 序列化/反序列化接口, 网络层和文件读写共用同一个codec注册表(RegisterCodec)
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+// Marshal 与encoding/json保持一致
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniter.Unmarshal(data, v)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (tomlCodec) Unmarshal(data []byte, v interface{}) error {
+	return toml.Unmarshal(data, v)
+}
+
+var codecLock sync.RWMutex
+
+// key: codec名称或content-type(小写, 不含参数)
+var codecs = map[string]Codec{
+	CodecJSON:            jsonCodec{},
+	"application/json":   jsonCodec{},
+	"text/json":          jsonCodec{},
+	CodecYAML:            yamlCodec{},
+	"application/yaml":   yamlCodec{},
+	"application/x-yaml": yamlCodec{},
+	"text/yaml":          yamlCodec{},
+	CodecTOML:            tomlCodec{},
+	"application/toml":   tomlCodec{},
+}
+
+// RegisterCodec 注册codec, name可以是codec名称(如"json"), 也可以是content-type(如"application/json"), 已存在则覆盖
+func RegisterCodec(name string, codec Codec) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	codecs[strings.ToLower(name)] = codec
+}
+
+// CodecFor 根据codec名称或content-type获取codec, content-type的参数(如charset)会被忽略, "+json"之类的后缀会匹配对应格式
+func CodecFor(name string) (Codec, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if mediaType, _, err := mime.ParseMediaType(name); err == nil {
+		name = mediaType
+	}
+
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	if codec, ok := codecs[name]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(name, "+"); i >= 0 {
+		codec, ok := codecs[name[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// codecOrJSON 找不到对应codec时使用json
+func codecOrJSON(name string) Codec {
+	if codec, ok := CodecFor(name); ok {
+		return codec
+	}
+	return jsonCodec{}
+}
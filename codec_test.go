@@ -0,0 +1,33 @@
+package tools
+
+import "testing"
+
+func TestCodecFor(t *testing.T) {
+	for name, want := range map[string]Codec{
+		"json":                            jsonCodec{},
+		"application/json; charset=utf-8": jsonCodec{},
+		"application/problem+json":        jsonCodec{},
+		"Application/X-YAML":              yamlCodec{},
+		"application/toml":                tomlCodec{},
+	} {
+		codec, ok := CodecFor(name)
+		if !ok || codec != want {
+			t.Error(name, codec, ok)
+		}
+	}
+
+	if _, ok := CodecFor("text/html"); ok {
+		t.Error("text/html should not have codec")
+	}
+
+	RegisterCodec("application/vnd.custom", yamlCodec{})
+	// 注册表是全局的, 测试结束后删除, 不影响其他测试
+	t.Cleanup(func() {
+		codecLock.Lock()
+		defer codecLock.Unlock()
+		delete(codecs, "application/vnd.custom")
+	})
+	if codec, _ := CodecFor("application/vnd.custom"); codec != (yamlCodec{}) {
+		t.Error(codec)
+	}
+}
@@ -0,0 +1,310 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeURL        = "http://connect.rom.miui.com/generate_204"
+	defaultMonitorInterval = 5 * time.Second
+	defaultMonitorTimeout  = 3 * time.Second
+)
+
+// Probe 连通性探测, 返回nil代表探测成功
+type Probe func(ctx context.Context) error
+
+// HTTPProbe 请求url, 状态码为expectStatus才算成功, 如"generate_204"类接口传204
+func HTTPProbe(url string, expectStatus int, options ...NetOptionFunc) Probe {
+	options = append([]NetOptionFunc{NetLogLevelOption(NetLogNone)}, options...)
+	return func(ctx context.Context) error {
+		res := new(http.Response)
+		err := Get(url, nil, res, append(options[:len(options):len(options)], NetContext(ctx))...)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != expectStatus {
+			return fmt.Errorf("probe %s: status %d, expect %d", url, res.StatusCode, expectStatus)
+		}
+		return nil
+	}
+}
+
+// TCPProbe 能否建立tcp连接, address格式为"host:port"
+func TCPProbe(address string) Probe {
+	return func(ctx context.Context) error {
+		conn, err := new(net.Dialer).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// DNSProbe 能否解析host
+func DNSProbe(host string) Probe {
+	return func(ctx context.Context) error {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err == nil && len(addrs) == 0 {
+			err = fmt.Errorf("probe %s: no address", host)
+		}
+		return err
+	}
+}
+
+// MonitorOptionFunc ConnectivityMonitor配置
+type MonitorOptionFunc func(o *monitorOptions)
+
+type monitorOptions struct {
+	Probes          []Probe       // default: HTTPProbe("http://connect.rom.miui.com/generate_204", 204)
+	Interval        time.Duration // default: 5s, <=0时使用默认值
+	Timeout         time.Duration // 单次探测超时, default: 3s, <=0时使用默认值
+	Quorum          int           // 至少几个probe成功才算在线, default: 1, 超过probe数量时按probe数量计算
+	OnlineAfter     int           // 连续几次检测在线才切换为在线, default: 1
+	OfflineAfter    int           // 连续几次检测离线才切换为离线, default: 2
+	InitiallyOnline bool          // 初始状态, default: true
+}
+
+// MonitorProbes 探测方式, 每次检测会并发执行所有probe
+func MonitorProbes(probes ...Probe) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.Probes = probes
+	}
+}
+
+// MonitorInterval 检测间隔
+func MonitorInterval(interval time.Duration) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.Interval = interval
+	}
+}
+
+// MonitorTimeout 单次探测超时
+func MonitorTimeout(timeout time.Duration) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// MonitorQuorum 至少quorum个probe成功才算在线
+func MonitorQuorum(quorum int) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.Quorum = quorum
+	}
+}
+
+// MonitorHysteresis 连续onlineAfter次在线才切换为在线, 连续offlineAfter次离线才切换为离线, 避免网络抖动时状态来回切换
+func MonitorHysteresis(onlineAfter int, offlineAfter int) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.OnlineAfter = onlineAfter
+		o.OfflineAfter = offlineAfter
+	}
+}
+
+// MonitorInitiallyOnline 初始状态
+func MonitorInitiallyOnline(online bool) MonitorOptionFunc {
+	return func(o *monitorOptions) {
+		o.InitiallyOnline = online
+	}
+}
+
+// ConnectivityMonitor 网络连通性监控, 并发安全
+type ConnectivityMonitor struct {
+	options monitorOptions
+
+	lock        sync.Mutex
+	online      bool
+	streak      int // 与当前状态相反的连续检测次数
+	subscribers map[chan bool]struct{}
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewConnectivityMonitor 创建监控, 需要调用Start才会开始定时检测
+func NewConnectivityMonitor(options ...MonitorOptionFunc) *ConnectivityMonitor {
+	o := monitorOptions{
+		Interval:        defaultMonitorInterval,
+		Timeout:         defaultMonitorTimeout,
+		Quorum:          1,
+		OnlineAfter:     1,
+		OfflineAfter:    2,
+		InitiallyOnline: true,
+	}
+	for _, option := range options {
+		option(&o)
+	}
+	if len(o.Probes) == 0 {
+		o.Probes = []Probe{HTTPProbe(defaultProbeURL, http.StatusNoContent)}
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultMonitorInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultMonitorTimeout
+	}
+	if o.Quorum < 1 {
+		o.Quorum = 1
+	}
+	if o.Quorum > len(o.Probes) {
+		o.Quorum = len(o.Probes)
+	}
+	if o.OnlineAfter < 1 {
+		o.OnlineAfter = 1
+	}
+	if o.OfflineAfter < 1 {
+		o.OfflineAfter = 1
+	}
+
+	return &ConnectivityMonitor{
+		options:     o,
+		online:      o.InitiallyOnline,
+		subscribers: make(map[chan bool]struct{}),
+	}
+}
+
+// Start 开始定时检测, 会立即检测一次, 重复调用无效
+func (m *ConnectivityMonitor) Start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+// Stop 停止检测, 会等待正在进行的检测结束
+func (m *ConnectivityMonitor) Stop() {
+	m.lock.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (m *ConnectivityMonitor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check 立即检测一次并更新状态, 返回更新后的状态
+func (m *ConnectivityMonitor) Check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, m.options.Timeout)
+	defer cancel()
+
+	results := make(chan error, len(m.options.Probes))
+	for _, probe := range m.options.Probes {
+		go func(probe Probe) {
+			results <- probe(ctx)
+		}(probe)
+	}
+
+	succeeded := 0
+	for range m.options.Probes {
+		if <-results == nil {
+			succeeded++
+		}
+	}
+
+	// 被Stop取消的检测结果不可信, 不更新状态
+	if ctx.Err() == context.Canceled {
+		return m.IsOnline()
+	}
+	m.record(succeeded >= m.options.Quorum)
+	return m.IsOnline()
+}
+
+// record 记录一次检测结果, 状态发生切换时返回true并通知订阅者
+func (m *ConnectivityMonitor) record(online bool) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if online == m.online {
+		m.streak = 0
+		return false
+	}
+
+	m.streak++
+	threshold := m.options.OfflineAfter
+	if online {
+		threshold = m.options.OnlineAfter
+	}
+	if m.streak < threshold {
+		return false
+	}
+
+	m.online = online
+	m.streak = 0
+	for ch := range m.subscribers {
+		// 不阻塞检测, 订阅者来不及处理时丢弃最早的一次切换, 保证最新的状态一定送达
+		select {
+		case ch <- online:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- online
+		}
+	}
+	return true
+}
+
+// IsOnline 当前是否在线
+func (m *ConnectivityMonitor) IsOnline() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.online
+}
+
+// Subscribe 订阅在线/离线状态切换, true代表切换为在线; 调用返回的cancel取消订阅并关闭channel
+// 订阅者来不及处理时会丢弃较早的切换, 但最后收到的一定是最新的状态
+func (m *ConnectivityMonitor) Subscribe() (ch <-chan bool, cancel func()) {
+	c := make(chan bool, 8)
+	m.lock.Lock()
+	m.subscribers[c] = struct{}{}
+	m.lock.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			m.lock.Lock()
+			delete(m.subscribers, c)
+			m.lock.Unlock()
+			close(c)
+		})
+	}
+}
+
+// OnChange 订阅状态切换, 在同一个goroutine中按切换的顺序调用fn; 调用返回的cancel取消订阅
+func (m *ConnectivityMonitor) OnChange(fn func(online bool)) (cancel func()) {
+	ch, cancel := m.Subscribe()
+	go func() {
+		for online := range ch {
+			fn(online)
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConnectivityMonitor(t *testing.T) {
+	var status int32 = http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	monitor := NewConnectivityMonitor(
+		MonitorProbes(HTTPProbe(server.URL, http.StatusNoContent), TCPProbe(server.Listener.Addr().String()), TCPProbe(closedAddr)),
+		MonitorQuorum(2),
+		MonitorHysteresis(1, 2),
+		MonitorInterval(20*time.Millisecond),
+		MonitorTimeout(time.Second),
+	)
+	ch, cancel := monitor.Subscribe()
+	defer cancel()
+
+	if !monitor.Check(context.Background()) {
+		t.Fatal("should be online")
+	}
+
+	// 只有一个probe成功, 达不到quorum, 需要连续两次才会切换为离线
+	atomic.StoreInt32(&status, http.StatusOK)
+	if !monitor.Check(context.Background()) {
+		t.Fatal("should stay online before hysteresis threshold")
+	}
+	if monitor.Check(context.Background()) {
+		t.Fatal("should be offline")
+	}
+	if online := <-ch; online {
+		t.Fatal("expect offline transition")
+	}
+
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	monitor.Start()
+	monitor.Start()
+	select {
+	case online := <-ch:
+		if !online {
+			t.Fatal("expect online transition")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for online transition")
+	}
+	monitor.Stop()
+	monitor.Stop()
+
+	if !monitor.IsOnline() {
+		t.Fatal("should be online")
+	}
+}
+
+func TestProbes(t *testing.T) {
+	ctx := context.Background()
+	if err := DNSProbe("localhost")(ctx); err != nil {
+		t.Error(err)
+	}
+	if err := TCPProbe("127.0.0.1:1")(ctx); err == nil {
+		t.Error("expect dial error")
+	}
+}
+
+func TestConnectivityMonitorOnChange(t *testing.T) {
+	var online int32 = 1
+	monitor := NewConnectivityMonitor(MonitorProbes(func(ctx context.Context) error {
+		if atomic.LoadInt32(&online) == 0 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}), MonitorHysteresis(1, 1))
+
+	changes := make(chan bool, 10)
+	var calling int32
+	cancel := monitor.OnChange(func(online bool) {
+		// 同一时间只有一个回调在执行
+		if atomic.AddInt32(&calling, 1) != 1 {
+			t.Error("concurrent callback")
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&calling, -1)
+		changes <- online
+	})
+	defer cancel()
+
+	for i := 0; i < 4; i++ {
+		atomic.StoreInt32(&online, int32(i%2))
+		monitor.Check(context.Background())
+	}
+	for i, expect := range []bool{false, true, false, true} {
+		select {
+		case online := <-changes:
+			if online != expect {
+				t.Fatal(i, online)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout", i)
+		}
+	}
+}
+
+func TestConnectivityMonitorSlowSubscriber(t *testing.T) {
+	var online int32 = 1
+	monitor := NewConnectivityMonitor(MonitorProbes(func(ctx context.Context) error {
+		if atomic.LoadInt32(&online) == 0 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}), MonitorHysteresis(1, 1), MonitorInterval(0), MonitorTimeout(-1))
+	if monitor.options.Interval != defaultMonitorInterval || monitor.options.Timeout != defaultMonitorTimeout {
+		t.Fatal(monitor.options)
+	}
+
+	ch, cancel := monitor.Subscribe()
+	// 切换次数超过channel容量, 最后收到的一定是最新的状态
+	for i := 0; i < 21; i++ {
+		atomic.StoreInt32(&online, int32(i%2))
+		monitor.Check(context.Background())
+	}
+	cancel()
+	var last bool
+	count := 0
+	for last = range ch {
+		count++
+	}
+	if count != cap(ch) || last {
+		t.Error(count, last)
+	}
+}
@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileParseError 文件解析错误, Line/Column从1开始, 为0代表解析器未提供位置信息
+type FileParseError struct {
+	Path   string
+	Format string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *FileParseError) Error() string {
+	position := e.Path
+	if e.Line > 0 {
+		position += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			position += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return fmt.Sprintf("parse %s file %s: %v", e.Format, position, e.Err)
+}
+
+func (e *FileParseError) Unwrap() error {
+	return e.Err
+}
+
+// LoadJSON 读取json文件并反序列化为T
+func LoadJSON[T any](path string) (T, error) {
+	return loadCodecFile[T](path, CodecJSON)
+}
+
+// SaveJSON 序列化为json并写入文件, indent为空则不缩进
+func SaveJSON(path string, v interface{}, indent string) error {
+	return saveJSON(path, v, indent, 0644)
+}
+
+// saveJSON 同SaveJSON, perm为新建文件的权限, 已存在的文件保持原有权限
+func saveJSON(path string, v interface{}, indent string, perm os.FileMode) error {
+	data, err := codecOrJSON(CodecJSON).Marshal(v)
+	if err != nil {
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+	if indent != "" {
+		buf := new(bytes.Buffer)
+		if err = json.Indent(buf, data, "", indent); err != nil {
+			return fmt.Errorf("save %s: %w", path, err)
+		}
+		data = buf.Bytes()
+	}
+	return writeFileAtomic(path, data, perm)
+}
+
+// LoadYAML 读取yaml文件并反序列化为T
+func LoadYAML[T any](path string) (T, error) {
+	return loadCodecFile[T](path, CodecYAML)
+}
+
+// SaveYAML 序列化为yaml并写入文件
+func SaveYAML(path string, v interface{}) error {
+	return saveCodecFile(path, CodecYAML, v)
+}
+
+// LoadTOML 读取toml文件并反序列化为T
+func LoadTOML[T any](path string) (T, error) {
+	return loadCodecFile[T](path, CodecTOML)
+}
+
+// SaveTOML 序列化为toml并写入文件
+func SaveTOML(path string, v interface{}) error {
+	return saveCodecFile(path, CodecTOML, v)
+}
+
+func loadCodecFile[T any](path string, format string) (T, error) {
+	var v T
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return v, err
+	}
+
+	codec, ok := CodecFor(format)
+	if !ok {
+		return v, fmt.Errorf("load %s: codec %q not registered", path, format)
+	}
+	if err = codec.Unmarshal(data, &v); err != nil {
+		parseErr := &FileParseError{Path: path, Format: format, Err: err}
+		parseErr.Line, parseErr.Column = errorPosition(format, data, &v, err)
+		return v, parseErr
+	}
+	return v, nil
+}
+
+func saveCodecFile(path string, format string, v interface{}) error {
+	codec, ok := CodecFor(format)
+	if !ok {
+		return fmt.Errorf("save %s: codec %q not registered", path, format)
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("save %s: %w", path, err)
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+var yamlColumnRegexp = regexp.MustCompile(`column (\d+)`)
+
+// errorPosition 尽量从解析错误中找出行列号
+func errorPosition(format string, data []byte, v interface{}, err error) (line int, column int) {
+	switch format {
+	case CodecJSON:
+		// jsoniter的错误信息中只有相对位置, 这里用encoding/json重新解析一次来定位
+		probe := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch probeErr := json.Unmarshal(data, probe); {
+		case errors.As(probeErr, &syntaxErr):
+			return offsetPosition(data, syntaxErr.Offset)
+		case errors.As(probeErr, &typeErr):
+			return offsetPosition(data, typeErr.Offset)
+		}
+	case CodecYAML:
+		if match := yamlLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+		if match := yamlColumnRegexp.FindStringSubmatch(err.Error()); match != nil {
+			column, _ = strconv.Atoi(match[1])
+		}
+	case CodecTOML:
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr.Position.Line, parseErr.Position.Col
+		}
+	}
+	return
+}
+
+// offsetPosition 字节偏移量转换为行列号
+func offsetPosition(data []byte, offset int64) (line int, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndexByte(before, '\n') - 1
+	if column < 1 {
+		column = 1
+	}
+	return
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再rename, 避免写到一半时文件损坏; 文件已存在则保留原有权限
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	if info, statErr := os.Stat(path); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
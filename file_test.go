@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fileConfig struct {
+	Name  string   `json:"name" yaml:"name" toml:"name"`
+	Port  int      `json:"port" yaml:"port" toml:"port"`
+	Hosts []string `json:"hosts" yaml:"hosts" toml:"hosts"`
+}
+
+func TestSaveLoadCodecFile(t *testing.T) {
+	dir := t.TempDir()
+	config := fileConfig{Name: "go-tools", Port: 8080, Hosts: []string{"a", "b"}}
+
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := SaveJSON(jsonPath, config, "  "); err != nil {
+		t.Fatal(err)
+	}
+	jsonConfig, err := LoadJSON[fileConfig](jsonPath)
+	if err != nil || jsonConfig.Name != config.Name || len(jsonConfig.Hosts) != 2 {
+		t.Fatal(jsonConfig, err)
+	}
+
+	yamlPath := filepath.Join(dir, "config.yaml")
+	if err = SaveYAML(yamlPath, config); err != nil {
+		t.Fatal(err)
+	}
+	yamlConfig, err := LoadYAML[fileConfig](yamlPath)
+	if err != nil || yamlConfig.Port != config.Port {
+		t.Fatal(yamlConfig, err)
+	}
+
+	tomlPath := filepath.Join(dir, "config.toml")
+	if err = SaveTOML(tomlPath, config); err != nil {
+		t.Fatal(err)
+	}
+	tomlConfig, err := LoadTOML[*fileConfig](tomlPath)
+	if err != nil || tomlConfig.Hosts[1] != "b" {
+		t.Fatal(tomlConfig, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Fatal("temp files left:", entries)
+	}
+}
+
+func TestLoadCodecFileError(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name   string
+		data   string
+		load   func(path string) error
+		line   int
+		column int
+	}{
+		{"bad.json", "{\n  \"name\": \"a\",\n  \"port\": x\n}", func(path string) error {
+			_, err := LoadJSON[fileConfig](path)
+			return err
+		}, 3, 11},
+		{"type.json", "{\n  \"port\": \"8080\"\n}", func(path string) error {
+			_, err := LoadJSON[fileConfig](path)
+			return err
+		}, 2, 16},
+		{"bad.yaml", "name: a\nhosts: [b]\nport: abc\n", func(path string) error {
+			_, err := LoadYAML[fileConfig](path)
+			return err
+		}, 3, 0},
+		{"bad.toml", "name = \"a\"\nport = = 1\n", func(path string) error {
+			_, err := LoadTOML[fileConfig](path)
+			return err
+		}, 2, 8},
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		SaveFile(path, []byte(c.data))
+		err := c.load(path)
+		var parseErr *FileParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatal(c.name, err)
+		}
+		if parseErr.Path != path || parseErr.Line != c.line || (c.column > 0 && parseErr.Column != c.column) {
+			t.Error(c.name, parseErr.Line, parseErr.Column, err)
+		}
+		Logln(err)
+	}
+
+	if _, err := LoadJSON[fileConfig](filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+}
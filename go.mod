@@ -2,7 +2,13 @@ module github.com/shenguanjiejie/go-tools/v3
 
 go 1.18
 
-require github.com/json-iterator/go v1.1.12
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/json-iterator/go v1.1.12
+	github.com/klauspost/compress v1.17.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// GraphQLRequest GraphQL请求体
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLLocation 错误在query中的位置
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError 响应中errors数组的一个错误
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"` // 字段名为string, 数组下标为float64
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, key := range e.Path {
+		path[i] = fmt.Sprint(key)
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// Code extensions中的code, 没有时为空
+func (e *GraphQLError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// GraphQLErrors 响应中的errors数组, 可以通过errors.As获取
+type GraphQLErrors []*GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+type graphQLResponse struct {
+	Data   jsoniter.RawMessage `json:"data"`
+	Errors GraphQLErrors       `json:"errors"`
+}
+
+// GraphQL 发送GraphQL请求, 将响应中的data解析到obj, obj可以为nil.
+// options中的UnmarshalPath相对于data, eg: UnmarshalPath([]interface{}{"user"})解析data.user.
+// 响应中有errors时返回GraphQLErrors, 此时data中已返回的部分仍会解析到obj
+func GraphQL(url string, graphQLRequest *GraphQLRequest, obj interface{}, options ...NetOptionFunc) error {
+	config := configWithOptions(options...)
+	// 保留调用方的日志级别, UnmarshalPath改为在data中解析
+	netOptions := make([]NetOptionFunc, 0, len(options)+2)
+	netOptions = append(netOptions, NetLogLevelOption(config.NetLogLevel))
+	netOptions = append(netOptions, options...)
+	netOptions = append(netOptions, UnmarshalPath(nil))
+
+	response := new(graphQLResponse)
+	if err := requestWithData(http.MethodPost, url, graphQLRequest, response, netOptions...); err != nil {
+		return err
+	}
+
+	data := []byte(response.Data)
+	if len(config.UnmarshalPath) > 0 && len(data) > 0 {
+		data = []byte(jsoniter.Get(data, config.UnmarshalPath...).ToString())
+	}
+	if obj != nil && len(data) > 0 && string(data) != "null" {
+		if err := codecOrJSON(CodecJSON).Unmarshal(data, obj); err != nil {
+			return err
+		}
+	}
+	if len(response.Errors) > 0 {
+		return response.Errors
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := new(GraphQLRequest)
+		body, _ := io.ReadAll(r.Body)
+		if err := codecOrJSON(CodecJSON).Unmarshal(body, request); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch request.OperationName {
+		case "GetUser":
+			if request.Variables["id"] != float64(1) {
+				t.Error(request.Variables)
+			}
+			fmt.Fprint(w, `{"data":{"user":{"id":1,"title":"hello"}}}`)
+		case "Partial":
+			fmt.Fprint(w, `{"data":{"user":{"id":2,"title":null}},"errors":[`+
+				`{"message":"forbidden","locations":[{"line":1,"column":20}],"path":["user","title"],"extensions":{"code":"FORBIDDEN"}},`+
+				`{"message":"slow"}]}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"message":"unknown operation"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	logNone := NetLogLevelOption(NetLogNone)
+	user := new(User)
+	err := GraphQL(server.URL, &GraphQLRequest{
+		Query:         "query GetUser($id: ID!) { user(id: $id) { id title } }",
+		Variables:     map[string]interface{}{"id": 1},
+		OperationName: "GetUser",
+	}, user, logNone, UnmarshalPath([]interface{}{"user"}))
+	if err != nil || user.ID != 1 || user.Title != "hello" {
+		t.Error(err, user)
+	}
+
+	// 部分成功时data仍会解析
+	result := struct{ User User }{}
+	err = GraphQL(server.URL, &GraphQLRequest{Query: "{ user { id title } }", OperationName: "Partial"}, &result, logNone)
+	var graphQLErrors GraphQLErrors
+	if !errors.As(err, &graphQLErrors) || len(graphQLErrors) != 2 || result.User.ID != 2 {
+		t.Fatal(err, result)
+	}
+	first := graphQLErrors[0]
+	if first.Code() != "FORBIDDEN" || first.Locations[0].Column != 20 || first.Error() != "forbidden (path: user.title)" {
+		t.Error(first, first.Code())
+	}
+	if err.Error() != "graphql: forbidden (path: user.title); slow" {
+		t.Error(err)
+	}
+
+	err = GraphQL(server.URL, &GraphQLRequest{Query: "{ x }"}, nil, logNone)
+	if !errors.As(err, &graphQLErrors) || graphQLErrors[0].Message != "unknown operation" {
+		t.Error(err)
+	}
+}
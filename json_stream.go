@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrStopDecode DecodeJSONEach的回调返回该错误时停止解析, DecodeJSONEach返回nil
+var ErrStopDecode = errors.New("stop decode")
+
+// streamBufferSize 流式解析的缓冲区大小
+const streamBufferSize = 4096
+
+// DecodeJSONStream 从r中流式解析json到obj, 不会读取完整的内容到内存,
+// path同UnmarshalPath, eg: []interface{}{"data", 0, "user"}, 只会解析路径所指的节点, 其余部分直接跳过
+func DecodeJSONStream(r io.Reader, obj interface{}, path ...interface{}) error {
+	iter := jsoniter.Parse(jsoniter.ConfigCompatibleWithStandardLibrary, r, streamBufferSize)
+	if err := walkJSONPath(iter, path); err != nil {
+		return err
+	}
+	if iter.WhatIsNext() == jsoniter.InvalidValue {
+		if iter.Error == nil || iter.Error == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return iter.Error
+	}
+	iter.ReadVal(obj)
+	return iterError(iter)
+}
+
+// DecodeJSONEach 逐个解析r中的元素并回调fn, 解析下一个元素前会等待fn返回:
+//
+//	顶层(或path所指的节点)为数组时, 逐个解析数组的元素, eg: [{...}, {...}]
+//	否则按NDJSON(换行或空白分隔的多个json)解析, 直到r结束; 指定了path时只解析该节点
+//
+// fn返回错误时停止解析并返回该错误, 返回ErrStopDecode时停止解析并返回nil
+func DecodeJSONEach[T any](r io.Reader, fn func(item T) error, path ...interface{}) error {
+	iter := jsoniter.Parse(jsoniter.ConfigCompatibleWithStandardLibrary, r, streamBufferSize)
+	if err := walkJSONPath(iter, path); err != nil {
+		return err
+	}
+
+	yield := func() error {
+		var item T
+		iter.ReadVal(&item)
+		if err := iterError(iter); err != nil {
+			return err
+		}
+		return fn(item)
+	}
+	var err error
+	switch {
+	case iter.WhatIsNext() == jsoniter.ArrayValue:
+		for iter.ReadArray() {
+			if err = yield(); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = iterError(iter)
+		}
+	case len(path) > 0:
+		err = yield()
+	default:
+		for {
+			if iter.WhatIsNext() == jsoniter.InvalidValue {
+				if iter.Error == nil {
+					err = errors.New("DecodeJSONEach: invalid json value")
+				} else if iter.Error != io.EOF {
+					err = iter.Error
+				}
+				break
+			}
+			if err = yield(); err != nil {
+				break
+			}
+		}
+	}
+	if err == ErrStopDecode {
+		return nil
+	}
+	return err
+}
+
+// DecodeJSONChan 同DecodeJSONEach, 解析出的元素通过channel返回, 元素解析完或出错后两个channel都会关闭,
+// 解析出错或ctx取消时errs会收到错误. 调用方需要持续读取items直到关闭, 或取消ctx
+func DecodeJSONChan[T any](ctx context.Context, r io.Reader, path ...interface{}) (items <-chan T, errs <-chan error) {
+	itemChan := make(chan T)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer close(itemChan)
+		err := DecodeJSONEach(r, func(item T) error {
+			select {
+			case itemChan <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}, path...)
+		if err != nil {
+			errChan <- err
+		}
+	}()
+	return itemChan, errChan
+}
+
+// walkJSONPath 跳过path之前的内容, 使iter停在path所指的节点
+func walkJSONPath(iter *jsoniter.Iterator, path []interface{}) error {
+	for i, key := range path {
+		found := false
+		switch key := key.(type) {
+		case string:
+			if iter.WhatIsNext() != jsoniter.ObjectValue {
+				return fmt.Errorf("json path %v: %v is not an object", path, path[:i])
+			}
+			// ReadObject在对象结束时也返回"", 无法区分空字符串的key, 找到时回调返回false, iter停在对应的值上
+			iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+				if field == key {
+					found = true
+					return false
+				}
+				iter.Skip()
+				return true
+			})
+		case int:
+			if iter.WhatIsNext() != jsoniter.ArrayValue {
+				return fmt.Errorf("json path %v: %v is not an array", path, path[:i])
+			}
+			for index := 0; iter.ReadArray(); index++ {
+				if index == key {
+					found = true
+					break
+				}
+				iter.Skip()
+			}
+		default:
+			return fmt.Errorf("json path %v: unsupported key type %T", path, key)
+		}
+		if err := iterError(iter); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("json path %v: %v not found", path, path[:i+1])
+		}
+	}
+	return nil
+}
+
+// iterError 忽略解析到末尾时的io.EOF
+func iterError(iter *jsoniter.Iterator) error {
+	if iter.Error == io.EOF {
+		return nil
+	}
+	return iter.Error
+}
@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONStream(t *testing.T) {
+	data := `{"code":0,"skip":{"a":[1,{"b":"}"}]},"data":{"list":[{"id":1,"title":"a"},{"id":2,"title":"b"}]}}`
+
+	user := new(User)
+	if err := DecodeJSONStream(strings.NewReader(data), user, "data", "list", 1); err != nil || user.ID != 2 || user.Title != "b" {
+		t.Error(err, user)
+	}
+	var code int
+	if err := DecodeJSONStream(strings.NewReader(data), &code, "code"); err != nil || code != 0 {
+		t.Error(err, code)
+	}
+	if err := DecodeJSONStream(strings.NewReader(data), user, "data", "list", 2); err == nil {
+		t.Error("expect not found")
+	}
+	if err := DecodeJSONStream(strings.NewReader(data), user, "code", "a"); err == nil {
+		t.Error("expect not an object")
+	}
+	if err := DecodeJSONStream(strings.NewReader(""), user); err != io.ErrUnexpectedEOF {
+		t.Error(err)
+	}
+	if err := DecodeJSONStream(strings.NewReader(`{"id":1`), user); err == nil {
+		t.Error("expect error")
+	}
+
+	// 空字符串的key
+	empty := `{"a":1,"":{"":{"id":3}},"b":2}`
+	if err := DecodeJSONStream(strings.NewReader(empty), user, "", ""); err != nil || user.ID != 3 {
+		t.Error(err, user)
+	}
+	var b int
+	if err := DecodeJSONStream(strings.NewReader(empty), &b, "b"); err != nil || b != 2 {
+		t.Error(err, b)
+	}
+	if err := DecodeJSONStream(strings.NewReader(`{"a":1}`), user, ""); err == nil {
+		t.Error("expect not found")
+	}
+}
+
+func TestDecodeJSONEach(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		path []interface{}
+	}{
+		{"array", `[{"id":1},{"id":2},{"id":3}]`, nil},
+		{"ndjson", "{\"id\":1}\n{\"id\":2}\n\n{\"id\":3}\n", nil},
+		{"path", `{"data":{"list":[{"id":1},{"id":2},{"id":3}]}}`, []interface{}{"data", "list"}},
+	}
+	for _, c := range cases {
+		var ids []int
+		err := DecodeJSONEach(strings.NewReader(c.data), func(user User) error {
+			ids = append(ids, user.ID)
+			return nil
+		}, c.path...)
+		if err != nil || len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+			t.Error(c.name, err, ids)
+		}
+	}
+
+	// 数字在末尾
+	var numbers []int
+	if err := DecodeJSONEach(strings.NewReader("1\n2"), func(n int) error {
+		numbers = append(numbers, n)
+		return nil
+	}); err != nil || len(numbers) != 2 {
+		t.Error(err, numbers)
+	}
+
+	count := 0
+	err := DecodeJSONEach(strings.NewReader(`[1,2,3]`), func(n int) error {
+		count++
+		if n == 2 {
+			return ErrStopDecode
+		}
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Error(err, count)
+	}
+
+	stop := errors.New("stop")
+	if err = DecodeJSONEach(strings.NewReader(`[1,2,3]`), func(n int) error { return stop }); err != stop {
+		t.Error(err)
+	}
+	if err = DecodeJSONEach(strings.NewReader(`{"id":1} x`), func(user User) error { return nil }); err == nil {
+		t.Error("expect invalid json error")
+	}
+	if err = DecodeJSONEach(strings.NewReader(`[{"id":1},{"id":`), func(user User) error { return nil }); err == nil {
+		t.Error("expect truncated error")
+	}
+}
+
+func TestDecodeJSONChan(t *testing.T) {
+	items, errs := DecodeJSONChan[User](context.Background(), strings.NewReader(`[{"id":1},{"id":2}]`))
+	var ids []int
+	for user := range items {
+		ids = append(ids, user.ID)
+	}
+	if err := <-errs; err != nil || len(ids) != 2 {
+		t.Error(err, ids)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	numbers, errs := DecodeJSONChan[int](ctx, strings.NewReader(`[1,2,3]`))
+	<-numbers
+	cancel()
+	// 没有读取items时, 解析协程会因ctx取消而退出
+	if err := <-errs; err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func TestNetStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":1,"title":"a"},{"id":2,"title":"b"}]}`))
+	}))
+	defer server.Close()
+
+	user := new(User)
+	if err := Get(server.URL, nil, user, NetStream(), UnmarshalPath([]interface{}{"data", 1})); err != nil || user.Title != "b" {
+		t.Error(err, user)
+	}
+	var tooLarge *ResponseTooLargeError
+	if err := Get(server.URL, nil, user, NetStream(), NetMaxResponseSize(10), NetLogLevelOption(NetLogNone)); !errors.As(err, &tooLarge) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSON-RPC 2.0 预定义的错误码
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+)
+
+// ErrRPCNoResponse 批量调用中服务端没有返回该调用的响应
+var ErrRPCNoResponse = errors.New("jsonrpc: no response")
+
+// RPCError JSON-RPC响应中的error对象
+type RPCError struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Data    jsoniter.RawMessage `json:"data,omitempty"` // 服务端返回的附加信息, 可以通过DecodeData解析
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: code %d, %s", e.Code, e.Message)
+}
+
+// DecodeData 将Data解析到obj
+func (e *RPCError) DecodeData(obj interface{}) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return codecOrJSON(CodecJSON).Unmarshal(e.Data, obj)
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *uint64     `json:"id,omitempty"` // 通知没有id
+}
+
+type rpcResponse struct {
+	Result jsoniter.RawMessage `json:"result"`
+	Error  *RPCError           `json:"error"`
+	ID     jsoniter.RawMessage `json:"id"`
+}
+
+// decode 将result解析到obj, 有error时返回*RPCError
+func (r *rpcResponse) decode(obj interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if obj == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return codecOrJSON(CodecJSON).Unmarshal(r.Result, obj)
+}
+
+// RPCCall 批量调用中的一个调用
+type RPCCall struct {
+	Method       string
+	Params       interface{} // 数组(按位置)或对象(按名称), nil时不发送
+	Result       interface{} // result解析到的对象, 指针类型, 可以为nil
+	Notification bool        // 通知, 没有id, 服务端不会返回响应
+	Err          error       // 该调用的错误, 服务端返回error时为*RPCError
+}
+
+// RPCClient JSON-RPC 2.0 over HTTP客户端, 自动分配递增的id, 并发安全
+type RPCClient struct {
+	id      uint64 // 放在第一个字段, 保证32位平台上atomic操作的对齐
+	url     string
+	options []NetOptionFunc
+}
+
+// NewRPCClient 创建RPCClient, options会作用于每个调用, 单次调用的options优先级更高
+func NewRPCClient(url string, options ...NetOptionFunc) *RPCClient {
+	return &RPCClient{url: url, options: options}
+}
+
+// Call 调用method, result解析到obj, 服务端返回error时返回*RPCError
+func (c *RPCClient) Call(method string, params interface{}, obj interface{}, options ...NetOptionFunc) error {
+	id := c.nextID()
+	response := new(rpcResponse)
+	if err := c.post(&rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: &id}, response, options); err != nil {
+		return err
+	}
+	return response.decode(obj)
+}
+
+// Notify 发送通知, 服务端不会返回响应
+func (c *RPCClient) Notify(method string, params interface{}, options ...NetOptionFunc) error {
+	return c.post(&rpcRequest{JSONRPC: "2.0", Method: method, Params: params}, nil, options)
+}
+
+// Batch 在一个请求中发送calls, 每个调用的结果和错误分别设置到call.Result和call.Err,
+// 返回值仅代表请求本身的错误(网络/解析等), 此时所有调用的Err也会设置为该错误
+func (c *RPCClient) Batch(calls []*RPCCall, options ...NetOptionFunc) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	requests := make([]*rpcRequest, len(calls))
+	ids := make(map[string]*RPCCall, len(calls))
+	for i, call := range calls {
+		call.Err = nil
+		requests[i] = &rpcRequest{JSONRPC: "2.0", Method: call.Method, Params: call.Params}
+		if !call.Notification {
+			id := c.nextID()
+			requests[i].ID = &id
+			ids[strconv.FormatUint(id, 10)] = call
+		}
+	}
+
+	// 全部为通知时服务端不会返回响应
+	body := new(jsoniter.RawMessage)
+	var obj interface{}
+	if len(ids) > 0 {
+		obj = body
+	}
+	err := c.post(requests, obj, options)
+	if err == nil && obj != nil {
+		err = matchRPCResponses(*body, ids)
+	}
+	if err != nil {
+		for _, call := range calls {
+			call.Err = err
+		}
+	}
+	return err
+}
+
+// matchRPCResponses 按id将响应分配给对应的调用
+func matchRPCResponses(body []byte, calls map[string]*RPCCall) error {
+	body = bytes.TrimSpace(body)
+	// 整个批量请求无效时(如解析失败), 服务端返回单个error对象
+	if len(body) > 0 && body[0] == '{' {
+		response := new(rpcResponse)
+		if err := codecOrJSON(CodecJSON).Unmarshal(body, response); err != nil {
+			return err
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		return fmt.Errorf("jsonrpc: unexpected batch response %s", body)
+	}
+
+	var responses []*rpcResponse
+	if err := codecOrJSON(CodecJSON).Unmarshal(body, &responses); err != nil {
+		return err
+	}
+	for _, call := range calls {
+		call.Err = ErrRPCNoResponse
+	}
+	for _, response := range responses {
+		call, ok := calls[string(bytes.Trim(response.ID, `"`))]
+		if !ok {
+			continue
+		}
+		call.Err = response.decode(call.Result)
+	}
+	return nil
+}
+
+func (c *RPCClient) nextID() uint64 {
+	return atomic.AddUint64(&c.id, 1)
+}
+
+func (c *RPCClient) post(data interface{}, obj interface{}, options []NetOptionFunc) error {
+	merged := make([]NetOptionFunc, 0, len(c.options)+len(options)+1)
+	merged = append(merged, c.options...)
+	merged = append(merged, options...)
+	merged = append(merged, func(o *netOptions) {
+		// 多了一层RPCClient方法的调用, result由RPCClient解析
+		o.LogCallerSkip++
+		o.LogLineSkip++
+		o.UnmarshalPath = nil
+	})
+	return requestWithData(http.MethodPost, c.url, data, obj, merged...)
+}
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// rpcTestServer 支持add/fail/notify三个方法, 记录收到的通知个数
+func rpcTestServer(t *testing.T, notifications *int32) *httptest.Server {
+	handle := func(request map[string]interface{}) string {
+		id, hasID := request["id"]
+		if !hasID {
+			atomic.AddInt32(notifications, 1)
+			return ""
+		}
+		switch request["method"] {
+		case "add":
+			params := request["params"].([]interface{})
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":%v}`, id, params[0].(float64)+params[1].(float64))
+		case "user":
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":{"id":1,"title":"%v"}}`, id, request["params"].(map[string]interface{})["title"])
+		default:
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"error":{"code":-32601,"message":"Method not found","data":{"method":"%v"}}}`, id, request["method"])
+		}
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "abc" {
+			t.Error(r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(string(body), "[") {
+			var requests []map[string]interface{}
+			if err := codecOrJSON(CodecJSON).Unmarshal(body, &requests); err != nil {
+				t.Error(err)
+			}
+			var responses []string
+			// 倒序返回, 按id匹配
+			for i := len(requests) - 1; i >= 0; i-- {
+				if response := handle(requests[i]); response != "" {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			fmt.Fprint(w, "["+strings.Join(responses, ",")+"]")
+			return
+		}
+		request := make(map[string]interface{})
+		if err := codecOrJSON(CodecJSON).Unmarshal(body, &request); err != nil {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`)
+			return
+		}
+		if request["jsonrpc"] != "2.0" {
+			t.Error(request)
+		}
+		if response := handle(request); response != "" {
+			fmt.Fprint(w, response)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestRPCClient(t *testing.T) {
+	var notifications int32
+	server := rpcTestServer(t, &notifications)
+	defer server.Close()
+
+	client := NewRPCClient(server.URL, NetLogLevelOption(NetLogNone), NetHeader(map[string][]string{"X-Token": {"abc"}}))
+	sum := 0
+	if err := client.Call("add", []int{1, 2}, &sum); err != nil || sum != 3 {
+		t.Error(err, sum)
+	}
+	user := new(User)
+	if err := client.Call("user", map[string]string{"title": "hello"}, user); err != nil || user.ID != 1 || user.Title != "hello" {
+		t.Error(err, user)
+	}
+
+	var rpcErr *RPCError
+	err := client.Call("missing", nil, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != RPCMethodNotFound {
+		t.Fatal(err)
+	}
+	data := map[string]string{}
+	if err = rpcErr.DecodeData(&data); err != nil || data["method"] != "missing" {
+		t.Error(err, data)
+	}
+
+	if err = client.Notify("log", []string{"x"}); err != nil || atomic.LoadInt32(&notifications) != 1 {
+		t.Error(err, notifications)
+	}
+}
+
+func TestRPCClientBatch(t *testing.T) {
+	var notifications int32
+	server := rpcTestServer(t, &notifications)
+	defer server.Close()
+
+	client := NewRPCClient(server.URL, NetLogLevelOption(NetLogNone), NetHeader(map[string][]string{"X-Token": {"abc"}}))
+	sums := make([]int, 2)
+	calls := []*RPCCall{
+		{Method: "add", Params: []int{1, 2}, Result: &sums[0]},
+		{Method: "log", Notification: true},
+		{Method: "missing"},
+		{Method: "add", Params: []int{3, 4}, Result: &sums[1]},
+	}
+	if err := client.Batch(calls); err != nil {
+		t.Fatal(err)
+	}
+	var rpcErr *RPCError
+	if calls[0].Err != nil || calls[1].Err != nil || !errors.As(calls[2].Err, &rpcErr) || calls[3].Err != nil {
+		t.Error(calls[0].Err, calls[1].Err, calls[2].Err, calls[3].Err)
+	}
+	if sums[0] != 3 || sums[1] != 7 || atomic.LoadInt32(&notifications) != 1 {
+		t.Error(sums, notifications)
+	}
+
+	// 全部为通知
+	if err := client.Batch([]*RPCCall{{Method: "a", Notification: true}, {Method: "b", Notification: true}}); err != nil || atomic.LoadInt32(&notifications) != 3 {
+		t.Error(err, notifications)
+	}
+
+	// 服务端对整个批量请求返回错误
+	err := matchRPCResponses([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`), nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != RPCParseError {
+		t.Error(err)
+	}
+	// 缺少响应
+	call := &RPCCall{Method: "add"}
+	if err = matchRPCResponses([]byte(`[]`), map[string]*RPCCall{"1": call}); err != nil || call.Err != ErrRPCNoResponse {
+		t.Error(err, call.Err)
+	}
+}
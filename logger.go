@@ -152,6 +152,13 @@ func log(format string, ln bool, ifErr func(), a ...interface{}) {
 		return
 	}
 
+	redactor := getRedactor()
+	redacted := make([]interface{}, len(newA))
+	for i, arg := range newA {
+		redacted[i] = redactor.redactArg(arg)
+	}
+	newA = redacted
+
 	if format == "" {
 		format = logFormat(newA) + format
 	}
@@ -161,6 +168,9 @@ func log(format string, ln bool, ifErr func(), a ...interface{}) {
 	}
 
 	finalFormat, slice := formatWithValues(pc, level, codeLine, format, newA...)
+	if len(redactor.patterns) > 0 {
+		finalFormat, slice = "%s", []interface{}{redactor.redactString(fmt.Sprintf(finalFormat, slice...))}
+	}
 
 	if logger == nil {
 		fmt.Printf(finalFormat, slice...)
@@ -2,7 +2,7 @@ package tools
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -10,6 +10,9 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 )
@@ -28,6 +31,8 @@ const (
 	// LogObj 打印反序列化后的obj
 	NetLogObj
 	NetLogError
+	// NetLogDump 打印实际发出的完整请求和收到的完整响应(包括请求头和重试), Authorization/Cookie会被隐藏, 不包含在NetLogAll中
+	NetLogDump
 	NetLogAllWithoutObj = NetLogURL | NetLogParams | NetLogResponse | NetLogError
 	NetLogAll           = NetLogAllWithoutObj | NetLogObj
 )
@@ -40,15 +45,20 @@ type httpConfig struct {
 	Body   io.Reader
 }
 
+// requestContentType 请求体的Content-Type, 通过NetHeader设置的优先
+func (c *httpConfig) requestContentType() string {
+	if value := c.Header.Get("Content-Type"); value != "" {
+		return value
+	}
+	return c.contentType
+}
+
 var defaultConfig = &httpConfig{netOptions: netOptions{NetLogLevel: NetLogAllWithoutObj}}
 
 // Get obj : body所序列化的对象, 指针类型, 如果为*http.Response类型, 则直接返回*http.Response
 func Get(urlStr string, values url.Values, obj interface{}, opions ...NetOptionFunc) error {
-	url := urlStr
+	url := appendQuery(urlStr, values)
 	iconfig := configWithOptions(opions...)
-	if values != nil {
-		url = fmt.Sprintf("%s?%s", urlStr, values.Encode())
-	}
 
 	iconfig.Method = http.MethodGet
 	iconfig.URL = url
@@ -59,11 +69,8 @@ func Get(urlStr string, values url.Values, obj interface{}, opions ...NetOptionF
 
 // Delete obj : body所序列化的对象, 指针类型, 如果为*http.Response类型, 则直接返回*http.Response
 func Delete(urlStr string, values url.Values, obj interface{}, opions ...NetOptionFunc) error {
-	url := urlStr
+	url := appendQuery(urlStr, values)
 	iconfig := configWithOptions(opions...)
-	if values != nil {
-		url = fmt.Sprintf("%s?%s", urlStr, values.Encode())
-	}
 
 	iconfig.Method = http.MethodDelete
 	iconfig.URL = url
@@ -74,18 +81,19 @@ func Delete(urlStr string, values url.Values, obj interface{}, opions ...NetOpti
 
 func requestWithData(method string, url string, data interface{}, obj interface{}, options ...NetOptionFunc) error {
 	iconfig := configWithOptions(options...)
-	jsonParams, _ := json.Marshal(data)
-
-	iconfig.URL = url
-	iconfig.Method = method
-	iconfig.Body = bytes.NewBuffer(jsonParams)
-	iconfig.Params = data
 	if iconfig.contentType == "" {
 		iconfig.contentType = "application/json"
 	}
-	err := request(obj, iconfig)
+	params, err := codecOrJSON(iconfig.requestContentType()).Marshal(data)
+	if err != nil {
+		return err
+	}
 
-	return err
+	iconfig.URL = url
+	iconfig.Method = method
+	iconfig.Body = bytes.NewBuffer(params)
+	iconfig.Params = data
+	return request(obj, iconfig)
 }
 
 // Post obj : body所序列化的对象, 指针类型, 如果为*http.Response类型, 则直接返回*http.Response
@@ -103,8 +111,7 @@ func FormDataPost(url string, data map[string]string, obj interface{}, options .
 	iconfig.Body = cmdResReqForm
 	iconfig.Params = data
 	iconfig.contentType = contentType
-	request(obj, iconfig)
-	return nil
+	return request(obj, iconfig)
 }
 
 func createMultipartFormBody(params map[string]string) (*bytes.Buffer, string) {
@@ -163,28 +170,84 @@ func configWithOptions(opions ...NetOptionFunc) *httpConfig {
 		}
 		return &httpConfig{netOptions: *config}
 	}
-	return defaultConfig
+	// 复制一份, 避免并发请求时修改同一个defaultConfig
+	config := *defaultConfig
+	return &config
 }
 
-func request(obj interface{}, config *httpConfig) error {
-	client := http.DefaultClient
+func request(obj interface{}, config *httpConfig) (err error) {
+	client := *http.DefaultClient
+	if config.Client != nil {
+		client = *config.Client
+	}
 	if config.NetLogLevel == NetLogNil {
 		config.NetLogLevel = NetLogAll
 	}
 	shouldLogError := LogCondition(config.NetLogLevel&NetLogError != 0)
 	callerLevel := LogCallerSkip(config.LogCallerSkip + 2)
 	lineLevel := LogLineSkip(config.LogLineSkip + 2)
+
+	rawURL := config.URL
+	if len(config.PathParams) > 0 || config.Query != nil {
+		query, err := EncodeQuery(config.Query)
+		if err == nil {
+			config.URL, err = BuildURL(config.URL, "", config.PathParams, query)
+		}
+		if err != nil {
+			Error(shouldLogError, callerLevel, lineLevel, err)
+			return err
+		}
+	}
 	Logln(LogCondition(config.NetLogLevel&NetLogURL != 0), callerLevel, lineLevel, config.Method, config.URL)
 	Logln(LogCondition(config.NetLogLevel&NetLogParams != 0), callerLevel, lineLevel, config.Params)
 
-	request, err := http.NewRequest(config.Method, config.URL, config.Body)
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	probe := startInstrumentation(ctx, config, rawURL)
+	if probe != nil {
+		ctx = probe.ctx
+		defer func() {
+			probe.end(err)
+		}()
+	}
+	var attempts *attemptTransport
+	if meta := config.response; meta != nil {
+		start := time.Now()
+		meta.URL = config.URL
+		defer func() {
+			meta.Duration = time.Since(start)
+			meta.Retries = attempts.retries()
+		}()
+	}
+	body, compressed := config.Body, false
+	if config.CompressEncoding != "" && body != nil && compressible(config.requestContentType()) {
+		if body, compressed, err = compressBody(body, config.CompressEncoding, config.CompressMinSize); err != nil {
+			Error(shouldLogError, callerLevel, lineLevel, err)
+			return err
+		}
+	}
+	request, err := http.NewRequestWithContext(ctx, config.Method, config.URL, body)
 	if err != nil {
 		Error(shouldLogError, callerLevel, lineLevel, err)
 		return err
 	}
 
 	if config.Header != nil {
-		request.Header = config.Header
+		request.Header = config.Header.Clone()
+	}
+
+	if config.BasicAuth != nil {
+		request.SetBasicAuth(config.BasicAuth[0], config.BasicAuth[1])
+	}
+
+	if compressed {
+		request.Header.Set("Content-Encoding", strings.ToLower(config.CompressEncoding))
+	}
+
+	if traceParent, ok := TraceParentFromContext(ctx); ok && request.Header.Get("Traceparent") == "" {
+		request.Header.Set("Traceparent", traceParent.String())
 	}
 
 	if config.Method != http.MethodGet && config.Method != http.MethodDelete && request.Header.Get("Content-Type") == "" {
@@ -196,32 +259,132 @@ func request(obj interface{}, config *httpConfig) error {
 		client.Timeout = config.Timeout
 	}
 
+	roundTripper := transport(client.Transport)
+	var decompressor *decompressTransport
+	if len(config.AcceptEncodings) > 0 {
+		decompressor = &decompressTransport{next: roundTripper, encodings: config.AcceptEncodings}
+		roundTripper = decompressor
+	}
+	if probe != nil || config.response != nil {
+		attempts = &attemptTransport{next: roundTripper}
+		roundTripper = attempts
+	}
+	if probe != nil {
+		probe.stats.RequestBytes = request.ContentLength
+		if request.Body == nil || request.Body == http.NoBody {
+			probe.stats.RequestBytes = 0
+		}
+		probe.attempts = attempts
+	}
+	var dumper *dumpTransport
+	if config.NetLogLevel&NetLogDump != 0 {
+		dumper = &dumpTransport{next: roundTripper, maxBodyBytes: config.MaxLogBytes}
+		roundTripper = dumper
+	}
+	if config.Signer != nil {
+		roundTripper = &signTransport{next: roundTripper, signer: config.Signer}
+	}
+	limiter := config.Limiter
+	if limiter == nil {
+		limiter = GetRateLimiter()
+	}
+	if limiter != nil {
+		roundTripper = &limitTransport{next: roundTripper, limiter: limiter, failFast: config.LimitFailFast}
+	}
+	breaker := config.Breaker
+	if breaker == nil {
+		breaker = GetCircuitBreaker()
+	}
+	if breaker != nil {
+		roundTripper = &breakerTransport{next: roundTripper, breaker: breaker, name: config.CircuitName}
+	}
+	cache := config.Cache
+	if cache == nil {
+		cache = GetCacheStore()
+	}
+	if cache != nil && config.Method == http.MethodGet && config.CacheMode != CacheBypass {
+		roundTripper = &cacheTransport{next: roundTripper, store: cache, mode: config.CacheMode, maxSize: config.MaxResponseSize}
+	}
+	if config.TokenSource != nil {
+		roundTripper = &authTransport{next: roundTripper, source: config.TokenSource, host: request.URL.Host}
+	}
+	client.Transport = roundTripper
+
 	response, err := client.Do(request)
+	if dumper != nil {
+		for _, dumped := range dumper.flush() {
+			Logln(callerLevel, lineLevel, dumped)
+		}
+	}
 	if err != nil {
 		Error(shouldLogError, callerLevel, lineLevel, err)
 		return err
 	}
+	if probe != nil {
+		probe.stats.StatusCode = response.StatusCode
+		probe.stats.ResponseBytes = response.ContentLength
+	}
+	if meta := config.response; meta != nil {
+		meta.StatusCode = response.StatusCode
+		meta.Header = response.Header
+		if response.Request != nil && response.Request.URL != nil {
+			meta.URL = response.Request.URL.String()
+		}
+	}
 
 	if obj != nil && reflect.TypeOf(obj) == reflect.TypeOf(response) {
+		if config.MaxResponseSize > 0 {
+			tooLarge := &ResponseTooLargeError{Limit: config.MaxResponseSize, ContentLength: response.ContentLength}
+			if response.ContentLength > config.MaxResponseSize {
+				response.Body.Close()
+				Error(shouldLogError, callerLevel, lineLevel, tooLarge)
+				return tooLarge
+			}
+			response.Body = &limitedBody{ReadCloser: response.Body, remaining: config.MaxResponseSize, err: tooLarge}
+		}
 		*(obj.(*http.Response)) = *response
-		Logln(LogCondition(config.NetLogLevel&NetLogResponse != 0), callerLevel, lineLevel, obj)
+		if config.NetLogLevel&NetLogResponse != 0 {
+			// 打印隐藏了Set-Cookie等响应头的副本
+			logged := *response
+			logged.Header, _ = getRedactor().redactHeader(response.Header)
+			Logln(callerLevel, lineLevel, &logged)
+		}
 		return nil
 	}
-	result, err := io.ReadAll(response.Body)
+	contentType := response.Header.Get("Content-Type")
+	if _, isJSON := codecOrJSON(contentType).(jsonCodec); config.Stream && obj != nil && isJSON {
+		read, err := streamResponse(response, obj, config, decompressor, callerLevel, lineLevel)
+		if probe != nil {
+			probe.stats.ResponseBytes = read
+		}
+		return err
+	}
+
+	result, err := readResponseBody(response.Body, response.ContentLength, config.MaxResponseSize)
 	defer response.Body.Close()
+	if probe != nil {
+		probe.stats.ResponseBytes = int64(len(result))
+	}
+	if config.response != nil {
+		config.response.Body = result
+	}
 	if err != nil {
 		Error(shouldLogError, callerLevel, lineLevel, err)
 		return err
 	}
 
-	Logln(LogCondition(config.NetLogLevel&NetLogResponse != 0), callerLevel, lineLevel, string(result))
+	Logln(LogCondition(config.NetLogLevel&NetLogResponse != 0), callerLevel, lineLevel,
+		logBody(result, contentType, config.MaxLogBytes)+decompressor.compression(int64(len(result))))
 
 	if obj != nil {
+		// UnmarshalPath仅支持json, 其他格式根据Content-Type从codec注册表中查找, 找不到则按json处理
+		codec := codecOrJSON(contentType)
 		if len(config.UnmarshalPath) > 0 {
 			value := jsoniter.Get(result, config.UnmarshalPath...)
 			result = []byte(value.ToString())
+			codec = codecOrJSON(CodecJSON)
 		}
-		err = jsoniter.Unmarshal(result, &obj)
+		err = codec.Unmarshal(result, obj)
 		if err != nil {
 			Error(shouldLogError, callerLevel, lineLevel, err)
 			return err
@@ -231,3 +394,58 @@ func request(obj interface{}, config *httpConfig) error {
 
 	return nil
 }
+
+// streamResponse 从响应体流式解析json到obj, read为读取的字节数
+func streamResponse(response *http.Response, obj interface{}, config *httpConfig, decompressor *decompressTransport, callerLevel LogOptionFunc, lineLevel LogOptionFunc) (read int64, err error) {
+	defer response.Body.Close()
+	shouldLogError := LogCondition(config.NetLogLevel&NetLogError != 0)
+	body := &countingReader{reader: response.Body}
+	if config.MaxResponseSize > 0 {
+		tooLarge := &ResponseTooLargeError{Limit: config.MaxResponseSize, ContentLength: response.ContentLength}
+		if response.ContentLength > config.MaxResponseSize {
+			Error(shouldLogError, callerLevel, lineLevel, tooLarge)
+			return 0, tooLarge
+		}
+		body.reader = &limitedBody{ReadCloser: response.Body, remaining: config.MaxResponseSize, err: tooLarge}
+	}
+
+	err = DecodeJSONStream(body, obj, config.UnmarshalPath...)
+	Logln(LogCondition(config.NetLogLevel&NetLogResponse != 0), callerLevel, lineLevel,
+		fmt.Sprintf("<streamed body, %s, %d bytes read>", response.Header.Get("Content-Type"), body.count)+decompressor.compression(body.count))
+	if err != nil {
+		Error(shouldLogError, callerLevel, lineLevel, err)
+		return body.count, err
+	}
+	Logln(LogCondition(config.NetLogLevel&NetLogObj != 0), callerLevel, lineLevel, obj)
+	return body.count, nil
+}
+
+// transportHolder atomic.Value不能保存nil和不同的具体类型
+type transportHolder struct {
+	roundTripper http.RoundTripper
+}
+
+var defaultTransport atomic.Value // transportHolder
+
+// SetTransport 设置全局默认的Transport, 作用于没有设置Transport的client(包括http.DefaultClient和Session), 传nil恢复为http.DefaultTransport,
+// 测试时可以替换为toolstest.MockTransport, 并发安全
+func SetTransport(roundTripper http.RoundTripper) {
+	defaultTransport.Store(transportHolder{roundTripper: roundTripper})
+}
+
+// GetTransport SetTransport设置的Transport, 没有设置时为nil
+func GetTransport() http.RoundTripper {
+	holder, _ := defaultTransport.Load().(transportHolder)
+	return holder.roundTripper
+}
+
+// transport nil时返回SetTransport设置的Transport, 没有设置时返回http.DefaultTransport
+func transport(roundTripper http.RoundTripper) http.RoundTripper {
+	if roundTripper != nil {
+		return roundTripper
+	}
+	if roundTripper = GetTransport(); roundTripper != nil {
+		return roundTripper
+	}
+	return http.DefaultTransport
+}
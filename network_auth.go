@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Token Authorization使用的token
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int64     `json:"expires_in"`
+	Expiry       time.Time `json:"-"` // 零值代表不过期
+}
+
+// Valid token不为空, 且在leeway时间后仍未过期
+func (t *Token) Valid(leeway time.Duration) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(leeway).Before(t.Expiry))
+}
+
+// Authorization Authorization请求头的值, token_type为空时使用Bearer
+func (t *Token) Authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// TokenSource 提供token, 实现需要并发安全
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// tokenInvalidator 收到401时让TokenSource丢弃该token, 实现了该接口的TokenSource会在401时刷新token并重试一次
+type tokenInvalidator interface {
+	Invalidate(token *Token)
+}
+
+type staticTokenSource struct {
+	token *Token
+}
+
+// StaticTokenSource 固定的Bearer token
+func StaticTokenSource(accessToken string) TokenSource {
+	return staticTokenSource{token: &Token{AccessToken: accessToken, TokenType: "Bearer"}}
+}
+
+func (s staticTokenSource) Token(context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+// OAuth2Config OAuth2 client credentials / refresh token配置
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RefreshToken string          // 不为空时使用refresh_token授权, 否则使用client_credentials授权
+	Params       url.Values      // 额外的参数, eg: audience
+	EarlyExpiry  time.Duration   // 提前多久刷新token, default: 30s
+	Options      []NetOptionFunc // 请求token时使用的配置, default: 只打印URL和错误, 避免打印出client secret
+}
+
+// OAuth2TokenSource 缓存token, 过期前自动刷新, 并发调用时只会刷新一次
+type OAuth2TokenSource struct {
+	config OAuth2Config
+	lock   sync.Mutex
+	token  *Token
+}
+
+// NewOAuth2TokenSource 创建OAuth2 token source
+func NewOAuth2TokenSource(config OAuth2Config) *OAuth2TokenSource {
+	if config.EarlyExpiry <= 0 {
+		config.EarlyExpiry = 30 * time.Second
+	}
+	return &OAuth2TokenSource{config: config}
+}
+
+// OAuth2Error token接口返回的错误
+type OAuth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	Body        string `json:"-"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("oauth2: token request failed with status %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("oauth2: %s %s (status %d)", e.Code, e.Description, e.StatusCode)
+}
+
+// Token 获取token, 未过期直接返回缓存的token
+func (s *OAuth2TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token.Valid(s.config.EarlyExpiry) {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate 丢弃token, 下次调用Token时重新获取
+func (s *OAuth2TokenSource) Invalidate(token *Token) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token == token {
+		// 保留refresh token用于下次刷新
+		s.token = &Token{RefreshToken: token.RefreshToken}
+	}
+}
+
+// fetch 需要持有锁
+func (s *OAuth2TokenSource) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	for key, values := range s.config.Params {
+		form[key] = values
+	}
+	refreshToken := s.config.RefreshToken
+	if s.token != nil && s.token.RefreshToken != "" {
+		refreshToken = s.token.RefreshToken
+	}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	options := append([]NetOptionFunc{NetLogLevelOption(NetLogURL | NetLogError), LogCallerSkipOption(-2), LogLineSkipOption(-2)}, s.config.Options...)
+	config := configWithOptions(append(options, NetContext(ctx))...)
+	config.Method = http.MethodPost
+	config.URL = s.config.TokenURL
+	config.Body = strings.NewReader(form.Encode())
+	config.contentType = "application/x-www-form-urlencoded"
+	config.BasicAuth = &[2]string{url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret)}
+
+	response := new(http.Response)
+	if err := request(response, config); err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		oauthErr := &OAuth2Error{StatusCode: response.StatusCode, Body: string(body)}
+		jsoniter.Unmarshal(body, oauthErr)
+		return nil, oauthErr
+	}
+	token := new(Token)
+	if err = jsoniter.Unmarshal(body, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, &OAuth2Error{StatusCode: response.StatusCode, Body: string(body)}
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// authTransport 设置Authorization, 收到401时刷新token重试一次.
+// 只发给原请求的host, 重定向到其他host时不带token, 避免泄露给第三方
+type authTransport struct {
+	next   http.RoundTripper
+	source TokenSource
+	host   string // 原请求的host(包含端口)
+}
+
+func (t *authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Host != t.host {
+		return t.next.RoundTrip(request)
+	}
+	token, err := t.source.Token(request.Context())
+	if err != nil {
+		return nil, err
+	}
+	authorized := request.Clone(request.Context())
+	authorized.Header.Set("Authorization", token.Authorization())
+	response, err := t.next.RoundTrip(authorized)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	invalidator, ok := t.source.(tokenInvalidator)
+	if !ok || (request.Body != nil && request.GetBody == nil) {
+		return response, nil
+	}
+	invalidator.Invalidate(token)
+	newToken, err := t.source.Token(request.Context())
+	if err != nil || newToken.AccessToken == token.AccessToken {
+		return response, nil
+	}
+
+	retry := request.Clone(request.Context())
+	if request.GetBody != nil {
+		if retry.Body, err = request.GetBody(); err != nil {
+			return response, nil
+		}
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	retry.Header.Set("Authorization", newToken.Authorization())
+	return t.next.RoundTrip(retry)
+}
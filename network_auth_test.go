@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNetAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); ok {
+			fmt.Fprintf(w, `{"title":"%s:%s"}`, username, password)
+			return
+		}
+		fmt.Fprintf(w, `{"title":"%s"}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	user := new(User)
+	Get(server.URL, nil, user, NetBasicAuth("name", "secret"), NetLogLevelOption(NetLogNone))
+	if user.Title != "name:secret" {
+		t.Fatal(user.Title)
+	}
+	Get(server.URL, nil, user, NetBearerToken("token"), NetLogLevelOption(NetLogNone))
+	if user.Title != "Bearer token" {
+		t.Fatal(user.Title)
+	}
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if clientID != "id" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		grant := r.PostForm.Get("grant_type")
+		if n > 1 && (grant != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh") {
+			t.Error("expect refresh token grant", r.PostForm)
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600,"refresh_token":"refresh"}`, n)
+	}))
+	defer tokenServer.Close()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// token-1 被服务端吊销
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body := make([]byte, r.ContentLength)
+		r.Body.Read(body)
+		fmt.Fprintf(w, `{"title":"%s","body":%q}`, r.Header.Get("Authorization"), body)
+	}))
+	defer server.Close()
+
+	source := NewOAuth2TokenSource(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret", Scopes: []string{"read"}})
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if token, err := source.Token(context.Background()); err != nil || token.AccessToken != "token-1" {
+				t.Error(token, err)
+			}
+		}()
+	}
+	wait.Wait()
+	if issued != 1 {
+		t.Fatal("concurrent refresh should be serialized", issued)
+	}
+
+	user := new(User)
+	if err := Post(server.URL, map[string]string{"a": "b"}, user, NetTokenSource(source), NetLogLevelOption(NetLogNone)); err != nil {
+		t.Fatal(err)
+	}
+	if user.Title != "Bearer token-2" || user.Body != `{"a":"b"}` || calls != 2 || issued != 2 {
+		t.Fatal(user, calls, issued)
+	}
+
+	badSource := NewOAuth2TokenSource(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "wrong"})
+	_, err := badSource.Token(context.Background())
+	if oauthErr, ok := err.(*OAuth2Error); !ok || oauthErr.Code != "invalid_client" {
+		t.Fatal(err)
+	}
+}
+
+func TestNetAuthRedirect(t *testing.T) {
+	third := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":"%s"}`, r.Header.Get("Authorization"))
+	}))
+	defer third.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/external":
+			http.Redirect(w, r, third.URL, http.StatusFound)
+		case "/internal":
+			http.Redirect(w, r, "/me", http.StatusFound)
+		default:
+			fmt.Fprintf(w, `{"title":"%s"}`, r.Header.Get("Authorization"))
+		}
+	}))
+	defer origin.Close()
+
+	logNone := NetLogLevelOption(NetLogNone)
+	user := new(User)
+	// 重定向到其他host时不带token
+	if err := Get(origin.URL+"/external", nil, user, NetBearerToken("token"), logNone); err != nil || user.Title != "" {
+		t.Error(err, user.Title)
+	}
+	if err := Get(origin.URL+"/internal", nil, user, NetTokenSource(StaticTokenSource("token")), logNone); err != nil || user.Title != "Bearer token" {
+		t.Error(err, user.Title)
+	}
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBatchAborted BatchFailFast时, 因其他请求失败(或ctx取消)而没有执行的请求返回该错误
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchRequest 批量请求中的一个请求
+type BatchRequest struct {
+	Method  string // default: GET
+	URL     string
+	Query   url.Values      // 合并到URL的query中
+	Data    interface{}     // 请求体, 同Post的data, 为nil时不发送请求体
+	Obj     interface{}     // 同Get的obj, 可以为nil
+	Options []NetOptionFunc // 该请求单独的配置, 优先级高于BatchNetOptions
+}
+
+// BatchResult 单个请求的结果, 与BatchRequest的顺序一致
+type BatchResult struct {
+	Index    int
+	Obj      interface{} // 即BatchRequest.Obj
+	Err      error
+	Duration time.Duration
+}
+
+// BatchOptionFunc Batch配置
+type BatchOptionFunc func(o *batchOptions)
+
+type batchOptions struct {
+	Context     context.Context
+	Concurrency int             // default: 8
+	FailFast    bool            // 有请求失败时取消正在执行的请求, 不再执行剩下的请求
+	NetOptions  []NetOptionFunc // 所有请求共用的配置, 如NetHTTPClient/NetRateLimiter/NetHeader等
+}
+
+// BatchContext 所有请求使用的context, 取消后不再执行剩下的请求
+func BatchContext(ctx context.Context) BatchOptionFunc {
+	return func(o *batchOptions) {
+		o.Context = ctx
+	}
+}
+
+// BatchConcurrency 最大并发数, default: 8
+func BatchConcurrency(concurrency int) BatchOptionFunc {
+	return func(o *batchOptions) {
+		o.Concurrency = concurrency
+	}
+}
+
+// BatchFailFast 有请求失败时取消正在执行的请求, 剩下的请求不再执行, 返回ErrBatchAborted
+func BatchFailFast() BatchOptionFunc {
+	return func(o *batchOptions) {
+		o.FailFast = true
+	}
+}
+
+// BatchNetOptions 所有请求共用的配置, 如共用的client/限流器/请求头等
+func BatchNetOptions(options ...NetOptionFunc) BatchOptionFunc {
+	return func(o *batchOptions) {
+		o.NetOptions = append(o.NetOptions, options...)
+	}
+}
+
+// Batch 并发执行requests, 返回与requests顺序一致的结果.
+// err: BatchFailFast时为导致失败的错误, 否则为第一个(按顺序)失败的请求的错误, 都成功时为nil
+func Batch(requests []BatchRequest, options ...BatchOptionFunc) (results []BatchResult, err error) {
+	o := batchOptions{Context: context.Background(), Concurrency: 8}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(o.Context)
+	defer cancel()
+
+	results = make([]BatchResult, len(requests))
+	var failOnce sync.Once
+	var failErr error
+	semaphore := make(chan struct{}, o.Concurrency)
+	waitGroup := new(sync.WaitGroup)
+	for i := range requests {
+		results[i] = BatchResult{Index: i, Obj: requests[i].Obj}
+		if ctx.Err() == nil {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			for k := i; k < len(requests); k++ {
+				results[k] = BatchResult{Index: k, Obj: requests[k].Obj, Err: ErrBatchAborted}
+			}
+			break
+		}
+
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+			start := time.Now()
+			requestErr := requests[i].do(ctx, o.NetOptions)
+			results[i].Duration = time.Since(start)
+			results[i].Err = requestErr
+			if requestErr != nil && o.FailFast {
+				failOnce.Do(func() {
+					failErr = requestErr
+					cancel()
+				})
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if failErr != nil {
+		return results, failErr
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+	return results, nil
+}
+
+func (r *BatchRequest) do(ctx context.Context, shared []NetOptionFunc) error {
+	// 在协程中执行, 打印tools内部的调用位置
+	options := make([]NetOptionFunc, 0, len(shared)+len(r.Options)+3)
+	options = append(options, LogCallerSkipOption(-2), LogLineSkipOption(-2))
+	options = append(options, shared...)
+	options = append(options, NetContext(ctx))
+	options = append(options, r.Options...)
+
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	urlStr := appendQuery(r.URL, r.Query)
+	if r.Data != nil {
+		return requestWithData(method, urlStr, r.Data, r.Obj, options...)
+	}
+	config := configWithOptions(options...)
+	config.Method = method
+	config.URL = urlStr
+	config.Params = r.Query
+	return request(r.Obj, config)
+}
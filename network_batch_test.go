@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		// 先发出的请求后返回
+		time.Sleep(time.Duration(10-id) * 2 * time.Millisecond)
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, `{"id":%d,"title":%q}`, id, string(body))
+			return
+		}
+		fmt.Fprintf(w, `{"id":%d,"title":"%s"}`, id, r.Header.Get("X-Batch"))
+	}))
+	defer server.Close()
+
+	var requests []BatchRequest
+	for i := 0; i < 10; i++ {
+		request := BatchRequest{URL: server.URL, Query: url.Values{"id": {strconv.Itoa(i)}}, Obj: new(User)}
+		if i == 9 {
+			request.Method = http.MethodPost
+			request.Data = "data"
+		}
+		requests = append(requests, request)
+	}
+	results, err := Batch(requests, BatchConcurrency(3),
+		BatchNetOptions(NetLogLevelOption(NetLogNone), NetHeader(map[string][]string{"X-Batch": {"shared"}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		user := result.Obj.(*User)
+		title := "shared"
+		if i == 9 {
+			title = `"data"`
+		}
+		if result.Index != i || result.Err != nil || user.ID != i || user.Title != title {
+			t.Error(i, result.Err, user)
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 || max < 2 {
+		t.Error(max)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if r.URL.Path == "/bad" {
+			w.Write([]byte("not json"))
+			return
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	requests := []BatchRequest{
+		{URL: server.URL + "/slow", Obj: new(User)},
+		{URL: server.URL + "/bad", Obj: new(User)},
+		{URL: server.URL + "/slow", Obj: new(User)},
+		{URL: server.URL + "/slow", Obj: new(User)},
+	}
+	options := BatchNetOptions(NetLogLevelOption(NetLogNone))
+
+	// 不fail fast时所有请求都会执行, 返回按顺序第一个错误
+	results, err := Batch(requests, options, BatchConcurrency(2))
+	if err == nil || err != results[1].Err || atomic.LoadInt32(&count) != 4 {
+		t.Error(err, count)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if results[i].Err != nil {
+			t.Error(i, results[i].Err)
+		}
+	}
+
+	atomic.StoreInt32(&count, 0)
+	results, err = Batch(requests, options, BatchConcurrency(2), BatchFailFast())
+	if err == nil || err != results[1].Err {
+		t.Error(err)
+	}
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Error(results[0].Err)
+	}
+	if results[3].Err != ErrBatchAborted || atomic.LoadInt32(&count) > 3 {
+		t.Error(results[3].Err, count)
+	}
+
+	// ctx已经取消时不执行任何请求
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	atomic.StoreInt32(&count, 0)
+	results, err = Batch(requests, options, BatchContext(ctx))
+	if err != ErrBatchAborted || len(results) != 4 || atomic.LoadInt32(&count) != 0 {
+		t.Error(err, count)
+	}
+}
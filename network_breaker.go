@@ -0,0 +1,289 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开时请求不会发出, 直接返回该错误
+var ErrCircuitOpen = errors.New("tools: circuit open")
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOptionFunc CircuitBreaker配置
+type BreakerOptionFunc func(o *breakerOptions)
+
+type breakerOptions struct {
+	ConsecutiveFailures int                                                   // 连续失败多少次打开熔断, 0代表不按连续失败判断, default: 5
+	FailureRatio        float64                                               // 统计窗口内失败比例达到多少打开熔断, 0代表不按比例判断, default: 0
+	MinRequests         int                                                   // 统计窗口内至少多少个请求才按比例判断, default: 10
+	Window              time.Duration                                         // 失败比例的统计窗口, default: 1m
+	Cooldown            time.Duration                                         // 打开后多久进入半开状态, default: 30s
+	HalfOpenRequests    int                                                   // 半开状态允许同时通过的试探请求数, 全部成功才关闭熔断, default: 1
+	OnStateChange       func(name string, from CircuitState, to CircuitState) // 状态变化回调, 同步调用
+	IsFailure           func(response *http.Response, err error) bool         // default: err不为nil(调用方主动取消除外)或状态码>=500
+}
+
+// BreakerConsecutiveFailures 连续失败n次打开熔断, 0代表不按连续失败判断
+func BreakerConsecutiveFailures(n int) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.ConsecutiveFailures = n
+	}
+}
+
+// BreakerFailureRatio window时间内请求数不少于minRequests且失败比例达到ratio时打开熔断
+func BreakerFailureRatio(ratio float64, minRequests int, window time.Duration) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.FailureRatio = ratio
+		o.MinRequests = minRequests
+		o.Window = window
+	}
+}
+
+// BreakerCooldown 打开后多久进入半开状态
+func BreakerCooldown(cooldown time.Duration) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.Cooldown = cooldown
+	}
+}
+
+// BreakerHalfOpenRequests 半开状态允许同时通过的试探请求数
+func BreakerHalfOpenRequests(n int) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.HalfOpenRequests = n
+	}
+}
+
+// BreakerOnStateChange 状态变化回调, eg: func(name string, from, to CircuitState) { Warn("circuit", name, from, "->", to) }
+func BreakerOnStateChange(onStateChange func(name string, from CircuitState, to CircuitState)) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.OnStateChange = onStateChange
+	}
+}
+
+// BreakerIsFailure 自定义哪些结果算作失败
+func BreakerIsFailure(isFailure func(response *http.Response, err error) bool) BreakerOptionFunc {
+	return func(o *breakerOptions) {
+		o.IsFailure = isFailure
+	}
+}
+
+type circuit struct {
+	state       CircuitState
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     int // 半开状态下正在进行的试探请求数
+	probed      int // 半开状态下已成功的试探请求数
+}
+
+// CircuitBreaker 熔断器, 默认按host区分, 可以通过NetCircuitName按接口区分, 并发安全
+type CircuitBreaker struct {
+	options  breakerOptions
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(options ...BreakerOptionFunc) *CircuitBreaker {
+	o := breakerOptions{
+		ConsecutiveFailures: 5,
+		MinRequests:         10,
+		Window:              time.Minute,
+		Cooldown:            30 * time.Second,
+		HalfOpenRequests:    1,
+		IsFailure:           defaultIsFailure,
+	}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.HalfOpenRequests < 1 {
+		o.HalfOpenRequests = 1
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = defaultIsFailure
+	}
+	return &CircuitBreaker{options: o, circuits: make(map[string]*circuit)}
+}
+
+func defaultIsFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, ErrRateLimited)
+	}
+	return response.StatusCode >= http.StatusInternalServerError
+}
+
+// State 获取name(host或NetCircuitName设置的名称)当前的状态
+func (b *CircuitBreaker) State(name string) CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.circuits[name]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.options.Cooldown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// Reset 重置name的状态为关闭
+func (b *CircuitBreaker) Reset(name string) {
+	b.lock.Lock()
+	c, ok := b.circuits[name]
+	delete(b.circuits, name)
+	b.lock.Unlock()
+	if ok && c.state != CircuitClosed {
+		b.notify(name, c.state, CircuitClosed)
+	}
+}
+
+// Allow 是否允许请求, 允许时返回done, 请求结束后必须调用done上报结果
+func (b *CircuitBreaker) Allow(name string) (done func(failed bool), err error) {
+	b.lock.Lock()
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{windowStart: time.Now()}
+		b.circuits[name] = c
+	}
+
+	var changed []CircuitState
+	if c.state == CircuitOpen {
+		if time.Since(c.openedAt) < b.options.Cooldown {
+			b.lock.Unlock()
+			return nil, ErrCircuitOpen
+		}
+		changed = b.transition(c, CircuitHalfOpen)
+	}
+	halfOpen := c.state == CircuitHalfOpen
+	if halfOpen {
+		if c.probing+c.probed >= b.options.HalfOpenRequests {
+			b.lock.Unlock()
+			b.notify(name, changed...)
+			return nil, ErrCircuitOpen
+		}
+		c.probing++
+	}
+	b.lock.Unlock()
+	b.notify(name, changed...)
+
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			b.record(name, c, halfOpen, failed)
+		})
+	}, nil
+}
+
+func (b *CircuitBreaker) record(name string, c *circuit, probe bool, failed bool) {
+	b.lock.Lock()
+	var changed []CircuitState
+	switch {
+	case probe:
+		c.probing--
+		if c.state != CircuitHalfOpen {
+			break
+		}
+		if failed {
+			changed = b.transition(c, CircuitOpen)
+		} else if c.probed++; c.probed >= b.options.HalfOpenRequests {
+			changed = b.transition(c, CircuitClosed)
+		}
+	case c.state == CircuitClosed:
+		now := time.Now()
+		if now.Sub(c.windowStart) >= b.options.Window {
+			c.requests, c.failures, c.windowStart = 0, 0, now
+		}
+		c.requests++
+		if !failed {
+			c.consecutive = 0
+			break
+		}
+		c.failures++
+		c.consecutive++
+		if (b.options.ConsecutiveFailures > 0 && c.consecutive >= b.options.ConsecutiveFailures) ||
+			(b.options.FailureRatio > 0 && c.requests >= b.options.MinRequests &&
+				float64(c.failures)/float64(c.requests) >= b.options.FailureRatio) {
+			changed = b.transition(c, CircuitOpen)
+		}
+	}
+	b.lock.Unlock()
+	b.notify(name, changed...)
+}
+
+// transition 需要持有锁, 返回[from, to]用于在锁外回调
+func (b *CircuitBreaker) transition(c *circuit, to CircuitState) []CircuitState {
+	from := c.state
+	c.state = to
+	c.consecutive, c.requests, c.failures, c.probed = 0, 0, 0, 0
+	c.windowStart = time.Now()
+	if to == CircuitOpen {
+		c.openedAt = c.windowStart
+	}
+	return []CircuitState{from, to}
+}
+
+func (b *CircuitBreaker) notify(name string, changed ...CircuitState) {
+	if len(changed) == 2 && b.options.OnStateChange != nil {
+		b.options.OnStateChange(name, changed[0], changed[1])
+	}
+}
+
+var defaultBreaker atomic.Value // *CircuitBreaker
+
+// SetCircuitBreaker 设置全局熔断器, 传nil关闭, 单次请求可以通过NetCircuitBreaker覆盖, 并发安全
+func SetCircuitBreaker(breaker *CircuitBreaker) {
+	defaultBreaker.Store(breaker)
+}
+
+// GetCircuitBreaker SetCircuitBreaker设置的熔断器, 没有设置时为nil
+func GetCircuitBreaker() *CircuitBreaker {
+	breaker, _ := defaultBreaker.Load().(*CircuitBreaker)
+	return breaker
+}
+
+// breakerTransport 熔断打开时不发出请求
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *CircuitBreaker
+	name    string
+}
+
+func (t *breakerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	name := t.name
+	if name == "" {
+		name = request.URL.Host
+	}
+	done, err := t.breaker.Allow(name)
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.next.RoundTrip(request)
+	done(t.breaker.options.IsFailure(response, err))
+	return response, err
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNetCircuitBreaker(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	var changes []CircuitState
+	breaker := NewCircuitBreaker(
+		BreakerConsecutiveFailures(2),
+		BreakerCooldown(50*time.Millisecond),
+		BreakerOnStateChange(func(name string, from, to CircuitState) {
+			Warn("circuit", name, from.String(), "->", to.String())
+			lock.Lock()
+			changes = append(changes, to)
+			lock.Unlock()
+		}),
+	)
+	options := []NetOptionFunc{NetCircuitBreaker(breaker), NetCircuitName("api"), NetLogLevelOption(NetLogNone)}
+	res := new(http.Response)
+
+	Get(server.URL, nil, res, options...)
+	Get(server.URL, nil, res, options...)
+	if breaker.State("api") != CircuitOpen {
+		t.Fatal("should be open", breaker.State("api"))
+	}
+	if err := Get(server.URL, nil, res, options...); !errors.Is(err, ErrCircuitOpen) || hits != 2 {
+		t.Fatal(err, hits)
+	}
+
+	// 半开状态试探失败, 重新打开
+	time.Sleep(60 * time.Millisecond)
+	Get(server.URL, nil, res, options...)
+	if breaker.State("api") != CircuitOpen || hits != 3 {
+		t.Fatal("should reopen", breaker.State("api"), hits)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&status, http.StatusOK)
+	if err := Get(server.URL, nil, res, options...); err != nil || breaker.State("api") != CircuitClosed {
+		t.Fatal(err, breaker.State("api"))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	expect := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(changes) != len(expect) {
+		t.Fatal(changes)
+	}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Fatal(changes)
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRatio(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConsecutiveFailures(0), BreakerFailureRatio(0.5, 4, time.Minute))
+	for _, failed := range []bool{true, false, false} {
+		done, err := breaker.Allow("host")
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(failed)
+	}
+	if breaker.State("host") != CircuitClosed {
+		t.Fatal("min requests not reached")
+	}
+	done, _ := breaker.Allow("host")
+	done(true)
+	if breaker.State("host") != CircuitOpen {
+		t.Fatal("should be open at 50% failures")
+	}
+	breaker.Reset("host")
+	if _, err := breaker.Allow("host"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetCircuitBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	defer SetCircuitBreaker(nil)
+	options := []NetOptionFunc{NetCircuitName("api"), NetLogLevelOption(NetLogNone)}
+
+	breaker := NewCircuitBreaker(BreakerConsecutiveFailures(1), BreakerCooldown(time.Minute))
+	SetCircuitBreaker(breaker)
+	if GetCircuitBreaker() != breaker {
+		t.Fatal("expect breaker")
+	}
+	Get(server.URL, nil, new(http.Response), options...)
+	if err := Get(server.URL, nil, new(http.Response), options...); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal(err)
+	}
+
+	// 请求过程中切换全局熔断器是并发安全的
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetCircuitBreaker(NewCircuitBreaker())
+		}()
+		go func() {
+			defer wg.Done()
+			Get(server.URL, nil, nil, options...)
+		}()
+	}
+	wg.Wait()
+	SetCircuitBreaker(nil)
+	if GetCircuitBreaker() != nil {
+		t.Fatal("expect nil")
+	}
+}
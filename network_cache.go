@@ -0,0 +1,353 @@
+package tools
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheMode 单次请求的缓存策略
+type CacheMode int
+
+const (
+	// CacheDefault 未过期直接使用缓存, 过期后带上ETag/Last-Modified进行条件请求
+	CacheDefault CacheMode = iota
+	// CacheBypass 不读也不写缓存
+	CacheBypass
+	// CacheRefresh 忽略已有缓存强制请求, 并用结果更新缓存
+	CacheRefresh
+)
+
+// CacheEntry 缓存的响应
+type CacheEntry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+	Expires    time.Time   `json:"expires"` // 零值代表每次都需要重新验证
+	// Vary 不为空时该条目只记录响应的Vary, 实际的响应按Vary中请求头的值分别缓存
+	Vary []string `json:"vary,omitempty"`
+}
+
+// Fresh 是否未过期
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return !e.Expires.IsZero() && now.Before(e.Expires)
+}
+
+// CacheStore 缓存存储, 实现需要并发安全
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+type cacheStoreHolder struct {
+	store CacheStore
+}
+
+var defaultCacheStore atomic.Value // cacheStoreHolder
+
+// SetCacheStore 设置全局默认的GET请求缓存, 传nil关闭, 单次请求可以通过NetCache覆盖, 并发安全
+func SetCacheStore(store CacheStore) {
+	defaultCacheStore.Store(cacheStoreHolder{store: store})
+}
+
+// GetCacheStore SetCacheStore设置的缓存, 没有设置时为nil
+func GetCacheStore() CacheStore {
+	holder, _ := defaultCacheStore.Load().(cacheStoreHolder)
+	return holder.store
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+type memoryCacheStore struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	lru      *list.List
+}
+
+// NewMemoryCacheStore 内存LRU缓存, 最多保存capacity个响应
+func NewMemoryCacheStore(capacity int) CacheStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &memoryCacheStore{capacity: capacity, items: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (s *memoryCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+func (s *memoryCacheStore) Set(key string, entry *CacheEntry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.items[key]; ok {
+		element.Value.(*memoryCacheItem).entry = entry
+		s.lru.MoveToFront(element)
+		return
+	}
+	s.items[key] = s.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for s.lru.Len() > s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.items, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (s *memoryCacheStore) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.items[key]; ok {
+		s.lru.Remove(element)
+		delete(s.items, key)
+	}
+}
+
+type diskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore 磁盘缓存, 每个响应以json文件保存在dir目录下, 文件名为key的md5
+func NewDiskCacheStore(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskCacheStore{dir: dir}, nil
+}
+
+func (s *diskCacheStore) path(key string) string {
+	return filepath.Join(s.dir, MD5(key)+".json")
+}
+
+func (s *diskCacheStore) Get(key string) (*CacheEntry, bool) {
+	entry, err := LoadJSON[*CacheEntry](s.path(key))
+	if err != nil || entry == nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (s *diskCacheStore) Set(key string, entry *CacheEntry) {
+	if err := SaveJSON(s.path(key), entry, ""); err != nil {
+		Warn(err)
+	}
+}
+
+func (s *diskCacheStore) Delete(key string) {
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		Warn(err)
+	}
+}
+
+// cacheTransport GET请求缓存, 按完整URL, 身份(Authorization/Cookie)和响应的Vary缓存,
+// 位于authTransport内层, 可以看到TokenSource设置的Authorization
+type cacheTransport struct {
+	next    http.RoundTripper
+	store   CacheStore
+	mode    CacheMode
+	maxSize int64 // NetMaxResponseSize, 缓存前读取响应体时同样生效
+}
+
+func (t *cacheTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet || t.mode == CacheBypass {
+		return t.next.RoundTrip(request)
+	}
+	// 请求的no-store不读也不写缓存, no-cache不使用已有缓存, 用结果更新缓存
+	directives := cacheControl(request.Header)
+	if _, noStore := directives["no-store"]; noStore {
+		return t.next.RoundTrip(request)
+	}
+	_, noCache := directives["no-cache"]
+	noCache = noCache || request.Header.Get("Pragma") == "no-cache"
+
+	original := request
+	baseKey := cacheKey(request)
+	key := baseKey
+	entry, ok := t.store.Get(key)
+	if ok && len(entry.Vary) > 0 {
+		key = varyKey(baseKey, entry.Vary, request)
+		entry, ok = t.store.Get(key)
+	}
+	if ok && t.mode != CacheRefresh && !noCache {
+		if entry.Fresh(time.Now()) {
+			return entry.response(request), nil
+		}
+		request = request.Clone(request.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			request.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && ok {
+		response.Body.Close()
+		updated := *entry
+		updated.Header = entry.Header.Clone()
+		for name, values := range response.Header {
+			updated.Header[name] = values
+		}
+		updated.StoredAt = time.Now()
+		updated.Expires = cacheExpires(updated.Header, updated.StoredAt)
+		t.store.Set(key, &updated)
+		return updated.response(request), nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return response, nil
+	}
+
+	if _, noStore := cacheControl(response.Header)["no-store"]; noStore {
+		t.store.Delete(key)
+		return response, nil
+	}
+	vary := varyNames(response.Header)
+	for _, name := range vary {
+		if name == "*" {
+			return response, nil
+		}
+	}
+
+	now := time.Now()
+	expires := cacheExpires(response.Header, now)
+	// 既没有过期时间也没有校验字段的响应缓存了也用不上
+	if expires.IsZero() && response.Header.Get("ETag") == "" && response.Header.Get("Last-Modified") == "" {
+		return response, nil
+	}
+
+	body, err := readResponseBody(response.Body, response.ContentLength, t.maxSize)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	key = baseKey
+	if len(vary) > 0 {
+		t.store.Set(baseKey, &CacheEntry{StoredAt: now, Vary: vary})
+		key = varyKey(baseKey, vary, original)
+	}
+	t.store.Set(key, &CacheEntry{
+		StatusCode: response.StatusCode,
+		Header:     response.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+		Expires:    expires,
+	})
+	return response, nil
+}
+
+// cacheKey 完整URL, 带有Authorization/Cookie时加上其md5, 不同身份的响应不会共用缓存
+func cacheKey(request *http.Request) string {
+	var credentials []string
+	for _, name := range []string{"Authorization", "Cookie"} {
+		credentials = append(credentials, request.Header.Values(name)...)
+	}
+	if len(credentials) == 0 {
+		return request.URL.String()
+	}
+	return request.URL.String() + "\ncredentials:" + MD5(strings.Join(credentials, "\n"))
+}
+
+// varyNames 响应Vary中的请求头名称, 规范化并排序
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// varyKey 在baseKey后加上Vary中请求头的值
+func varyKey(baseKey string, vary []string, request *http.Request) string {
+	builder := new(strings.Builder)
+	builder.WriteString(baseKey)
+	for _, name := range vary {
+		builder.WriteString("\nvary:" + name + "=" + strings.Join(request.Header.Values(name), ","))
+	}
+	return builder.String()
+}
+
+// response 用缓存构造响应, 会带上"X-From-Cache: 1"
+func (e *CacheEntry) response(request *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       request,
+	}
+}
+
+// cacheControl 解析Cache-Control, key为小写的指令名
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// cacheExpires 根据Cache-Control(max-age/no-cache)和Expires计算过期时间, 零值代表需要重新验证
+func cacheExpires(header http.Header, now time.Time) time.Time {
+	directives := cacheControl(header)
+	if _, noCache := directives["no-cache"]; noCache {
+		return time.Time{}
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds <= 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil && t.After(now) {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNetCache(t *testing.T) {
+	var hits, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%d,"title":"%s"}`, atomic.LoadInt32(&hits), r.URL.Path)
+	}))
+	defer server.Close()
+
+	disk, err := NewDiskCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []CacheStore{NewMemoryCacheStore(10), disk} {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&notModified, 0)
+		get := func(path string, options ...NetOptionFunc) *User {
+			user := new(User)
+			options = append(options, NetCache(store), NetLogLevelOption(NetLogNone))
+			if err := Get(server.URL+path, nil, user, options...); err != nil {
+				t.Fatal(err)
+			}
+			return user
+		}
+
+		if first, second := get("/fresh"), get("/fresh"); first.ID != 1 || second.ID != 1 || hits != 1 {
+			t.Fatal("max-age", first, second, hits)
+		}
+		if refreshed := get("/fresh", NetCacheMode(CacheRefresh)); refreshed.ID != 2 {
+			t.Fatal("refresh", refreshed)
+		}
+		if bypassed := get("/fresh", NetCacheMode(CacheBypass)); bypassed.ID != 3 || get("/fresh").ID != 2 {
+			t.Fatal("bypass", bypassed)
+		}
+
+		first, second := get("/etag"), get("/etag")
+		if first.ID != 4 || second.ID != 4 || hits != 5 || notModified != 1 {
+			t.Fatal("etag", first, second, hits, notModified)
+		}
+
+		get("/no-store")
+		if _, ok := store.Get(server.URL + "/no-store"); ok {
+			t.Fatal("no-store should not be cached")
+		}
+
+		res := new(http.Response)
+		Get(server.URL+"/fresh", nil, res, NetCache(store), NetLogLevelOption(NetLogNone))
+		if res.Header.Get("X-From-Cache") != "1" {
+			t.Fatal("expect cached *http.Response")
+		}
+	}
+}
+
+func TestMemoryCacheStoreLRU(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	store.Set("a", &CacheEntry{})
+	store.Set("b", &CacheEntry{})
+	store.Get("a")
+	store.Set("c", &CacheEntry{})
+	if _, ok := store.Get("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Fatal("a should be kept")
+	}
+	store.Delete("a")
+	if _, ok := store.Get("a"); ok {
+		t.Fatal("a should be deleted")
+	}
+}
+
+func TestNetCacheKey(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/vary" {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%d,"title":"%s%s"}`, hit, r.Header.Get("Authorization"), r.Header.Get("Accept-Language"))
+	}))
+	defer server.Close()
+
+	store := NewMemoryCacheStore(10)
+	get := func(path string, options ...NetOptionFunc) *User {
+		user := new(User)
+		options = append(options, NetCache(store), NetLogLevelOption(NetLogNone))
+		if err := Get(server.URL+path, nil, user, options...); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	header := func(key, value string) NetOptionFunc {
+		return NetHeader(http.Header{key: {value}})
+	}
+
+	// 不同身份的响应不共用缓存, TokenSource设置的Authorization同样生效
+	if a, b := get("/private", header("Authorization", "Bearer a")), get("/private", NetTokenSource(StaticTokenSource("b"))); a.Title != "Bearer a" || b.Title != "Bearer b" {
+		t.Fatal(a, b)
+	}
+	if a := get("/private", header("Authorization", "Bearer a")); a.ID != 1 || hits != 2 {
+		t.Fatal(a, hits)
+	}
+	if anonymous := get("/private"); anonymous.ID != 3 || anonymous.Title != "" {
+		t.Fatal(anonymous)
+	}
+
+	// Vary中的请求头不同时分别缓存
+	zh, en := get("/vary", header("Accept-Language", "zh")), get("/vary", header("Accept-Language", "en"))
+	if zh.Title != "zh" || en.Title != "en" || get("/vary", header("Accept-Language", "zh")).ID != zh.ID || hits != 5 {
+		t.Fatal(zh, en, hits)
+	}
+
+	// 请求的no-cache不使用缓存但会更新缓存, no-store不读也不写
+	if refreshed := get("/private", header("Cache-Control", "no-cache")); refreshed.ID != 6 || get("/private").ID != 6 {
+		t.Fatal(refreshed)
+	}
+	if bypassed := get("/private", header("Cache-Control", "no-store")); bypassed.ID != 7 || get("/private").ID != 6 {
+		t.Fatal(bypassed)
+	}
+}
+
+func TestNetCacheMaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		// 不设置Content-Length, 只能在读取时发现超过限制
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	defer server.Close()
+
+	store := NewMemoryCacheStore(10)
+	var tooLarge *ResponseTooLargeError
+	err := Get(server.URL, nil, new(http.Response), NetCache(store), NetMaxResponseSize(100), NetLogLevelOption(NetLogNone))
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get(server.URL); ok {
+		t.Error("too large response should not be cached")
+	}
+}
+
+func TestSetCacheStore(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, `{"id":1}`)
+	}))
+	defer server.Close()
+	defer SetCacheStore(nil)
+	logNone := NetLogLevelOption(NetLogNone)
+
+	store := NewMemoryCacheStore(10)
+	SetCacheStore(store)
+	if GetCacheStore() != store {
+		t.Fatal("expect store")
+	}
+	for i := 0; i < 2; i++ {
+		if err := Get(server.URL, nil, new(User), logNone); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Fatal(hits)
+	}
+
+	// 请求过程中切换全局缓存是并发安全的
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetCacheStore(NewMemoryCacheStore(10))
+		}()
+		go func() {
+			defer wg.Done()
+			Get(server.URL, nil, new(User), logNone)
+		}()
+	}
+	wg.Wait()
+	SetCacheStore(nil)
+	if GetCacheStore() != nil {
+		t.Fatal("expect nil")
+	}
+}
@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-Encoding
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate" // zlib格式, 同net/http和大多数服务端
+	EncodingZstd    = "zstd"
+	EncodingBrotli  = "br"
+)
+
+// defaultAcceptEncodings NetAcceptEncoding不传参数时的顺序
+var defaultAcceptEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+
+type contentEncoding struct {
+	encoder func(w io.Writer) (io.WriteCloser, error)
+	decoder func(r io.Reader) (io.ReadCloser, error)
+}
+
+var contentEncodings = map[string]contentEncoding{
+	EncodingGzip: {
+		encoder: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		decoder: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	EncodingDeflate: {
+		encoder: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+		decoder: zlib.NewReader,
+	},
+	EncodingZstd: {
+		encoder: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+		decoder: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	EncodingBrotli: {
+		encoder: func(w io.Writer) (io.WriteCloser, error) { return brotli.NewWriter(w), nil },
+		decoder: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+	},
+}
+
+// compressBody 按encoding压缩body, 不足minSize字节时不压缩, compressed为false
+func compressBody(body io.Reader, encoding string, minSize int) (result io.Reader, compressed bool, err error) {
+	contentEncoding, ok := contentEncodings[strings.ToLower(encoding)]
+	if !ok || contentEncoding.encoder == nil {
+		return body, false, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return body, false, err
+	}
+	if len(data) < minSize {
+		return bytes.NewReader(data), false, nil
+	}
+	buf := new(bytes.Buffer)
+	writer, err := contentEncoding.encoder(buf)
+	if err != nil {
+		return bytes.NewReader(data), false, err
+	}
+	if _, err = writer.Write(data); err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return bytes.NewReader(data), false, err
+	}
+	return bytes.NewReader(buf.Bytes()), true, nil
+}
+
+// compressible 只压缩json请求体, 很多服务端不支持压缩的multipart/表单请求体
+func compressible(contentType string) bool {
+	codec, ok := CodecFor(contentType)
+	_, isJSON := codec.(jsonCodec)
+	return ok && isJSON
+}
+
+// decompressTransport 设置Accept-Encoding并解压响应体, 每个请求一个实例, 记录最后一个响应压缩前的大小
+type decompressTransport struct {
+	next      http.RoundTripper
+	encodings []string
+	encoding  string          // 响应的Content-Encoding, 没有压缩时为空
+	raw       *countingReader // 从连接读取的(压缩的)字节数
+}
+
+func (t *decompressTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header.Get("Accept-Encoding") == "" {
+		request = request.Clone(request.Context())
+		request.Header.Set("Accept-Encoding", strings.Join(t.encodings, ", "))
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	encoding := strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding")))
+	contentEncoding, ok := contentEncodings[encoding]
+	if !ok || response.Body == nil || response.Body == http.NoBody || request.Method == http.MethodHead {
+		return response, nil
+	}
+
+	raw := &countingReader{reader: response.Body}
+	decoder, err := contentEncoding.decoder(raw)
+	if err != nil {
+		// 空响应体时gzip等会返回io.EOF
+		if err != io.EOF {
+			response.Body.Close()
+			return nil, fmt.Errorf("decompress %s response: %w", encoding, err)
+		}
+		decoder = io.NopCloser(bytes.NewReader(nil))
+	}
+	t.encoding, t.raw = encoding, raw
+	response.Body = &decompressedBody{ReadCloser: decoder, body: response.Body}
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return response, nil
+}
+
+// compression 响应压缩前后的大小, 用于日志, eg: " <br, 120 -> 560 bytes>", 响应没有压缩时为空
+func (t *decompressTransport) compression(uncompressed int64) string {
+	if t == nil || t.raw == nil {
+		return ""
+	}
+	return fmt.Sprintf(" <%s, %d -> %d bytes>", t.encoding, t.raw.count, uncompressed)
+}
+
+// decompressedBody 关闭时同时关闭解压器和原始响应体
+type decompressedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decompressedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
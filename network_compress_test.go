@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestNetCompressRequest(t *testing.T) {
+	type received struct {
+		encoding string
+		body     string
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		var reader io.Reader = r.Body
+		if encoding != "" {
+			decoder, err := contentEncodings[encoding].decoder(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer decoder.Close()
+			reader = decoder
+		}
+		body, _ := io.ReadAll(reader)
+		requests <- received{encoding: encoding, body: string(body)}
+	}))
+	defer server.Close()
+	logNone := NetLogLevelOption(NetLogNone)
+
+	data := map[string]string{"title": strings.Repeat("hello", 100)}
+	expect, _ := jsoniter.MarshalToString(data)
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate, EncodingZstd, EncodingBrotli} {
+		if err := Post(server.URL, data, nil, logNone, NetCompressRequest(encoding, 100)); err != nil {
+			t.Fatal(encoding, err)
+		}
+		if request := <-requests; request.encoding != encoding || request.body != expect {
+			t.Error(encoding, request)
+		}
+	}
+
+	// 不足minSize时不压缩
+	Post(server.URL, map[string]string{"title": "hello"}, nil, logNone, NetCompressRequest(EncodingGzip, 100))
+	if request := <-requests; request.encoding != "" || request.body != `{"title":"hello"}` {
+		t.Error(request)
+	}
+
+	if err := Post(server.URL, data, nil, logNone, NetCompressRequest("lzw", 0)); err == nil {
+		t.Error("expect unsupported encoding error")
+	}
+
+	// 只压缩json请求体
+	form := map[string]string{"title": strings.Repeat("hello", 100)}
+	FormDataPost(server.URL, form, nil, logNone, NetCompressRequest(EncodingGzip, 0))
+	if request := <-requests; request.encoding != "" || !strings.Contains(request.body, form["title"]) {
+		t.Error(request)
+	}
+	Post(server.URL, data, nil, logNone, NetCompressRequest(EncodingGzip, 0), NetHeader(http.Header{"Content-Type": {"application/vnd.api+json"}}))
+	if request := <-requests; request.encoding != EncodingGzip || request.body != expect {
+		t.Error(request)
+	}
+}
+
+func TestNetAcceptEncoding(t *testing.T) {
+	body := []byte(`{"id":1,"title":"` + strings.Repeat("hello", 100) + `"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimSpace(strings.Split(r.Header.Get("Accept-Encoding"), ",")[0])
+		w.Header().Set("Content-Type", "application/json")
+		if encoding == "identity" {
+			w.Write(body)
+			return
+		}
+		buf := new(bytes.Buffer)
+		writer, _ := contentEncodings[encoding].encoder(buf)
+		writer.Write(body)
+		writer.Close()
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	capture := new(captureLogger)
+	SetLogger(capture)
+	defer SetLogger(nil)
+
+	for _, encoding := range []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate} {
+		capture.lines = nil
+		result := new(User)
+		if err := Get(server.URL, nil, result, NetAcceptEncoding(encoding, EncodingGzip)); err != nil || result.ID != 1 || len(result.Title) != 500 {
+			t.Fatal(encoding, err, result)
+		}
+		log := strings.Join(capture.lines, "\n")
+		if !strings.Contains(log, " <"+encoding+", ") || !strings.Contains(log, fmt.Sprintf("-> %d bytes>", len(body))) {
+			t.Error(log)
+		}
+	}
+
+	// 不传参数时使用默认顺序
+	response := new(http.Response)
+	if err := Get(server.URL, nil, response, NetAcceptEncoding()); err != nil || !response.Uncompressed || response.Header.Get("Content-Encoding") != "" {
+		t.Fatal(err, response.Header)
+	}
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if !bytes.Equal(data, body) {
+		t.Error(string(data))
+	}
+
+	// 没有压缩时不打印压缩信息
+	capture.lines = nil
+	if err := Get(server.URL, nil, new(User), NetAcceptEncoding("identity")); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(capture.lines, "\n"), "bytes>") {
+		t.Error(capture.lines)
+	}
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// readBody 读取请求体并恢复, 优先使用GetBody, 不会消耗原请求体
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	if request.GetBody != nil {
+		reader, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	body, err := io.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// DumpRequest 按发送时的格式输出请求(包括请求头和请求体), 按SetRedaction的规则隐藏请求头和json请求体中的敏感数据, 不会消耗请求体
+func DumpRequest(request *http.Request) (string, error) {
+	return dumpRequest(request, 0)
+}
+
+// dumpRequest maxBodyBytes大于0时截断请求体
+func dumpRequest(request *http.Request, maxBodyBytes int) (string, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return "", err
+	}
+	dumped := request.Clone(request.Context())
+	dumped.Header, _ = getRedactor().redactHeader(request.Header)
+	head, err := httputil.DumpRequestOut(dumped, false)
+	if err != nil {
+		return "", err
+	}
+	return string(head) + logBody(body, request.Header.Get("Content-Type"), maxBodyBytes), nil
+}
+
+// DumpResponse 输出响应(包括响应头和响应体), 按SetRedaction的规则隐藏敏感数据, 响应体读取后会替换为内存中的副本
+func DumpResponse(response *http.Response) (string, error) {
+	return dumpResponse(response, 0)
+}
+
+// defaultDumpBodyBytes NetLogDump没有设置NetMaxLogBytes时最多读取的响应体字节数
+const defaultDumpBodyBytes = 64 << 10
+
+// dumpResponse maxBodyBytes大于0时只读取maxBodyBytes+1字节用于输出, 响应体替换为已读取的部分加上未读取的原响应体,
+// 不会破坏NetMaxResponseSize和NetStream. 读取出错时返回错误, 响应体不可再使用
+func dumpResponse(response *http.Response, maxBodyBytes int) (string, error) {
+	var body []byte
+	if response.Body != nil && response.Body != http.NoBody {
+		var err error
+		if maxBodyBytes > 0 {
+			body, err = io.ReadAll(io.LimitReader(response.Body, int64(maxBodyBytes)+1))
+			response.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), response.Body), Closer: response.Body}
+		} else {
+			body, err = io.ReadAll(response.Body)
+			response.Body.Close()
+			response.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	dumped := *response
+	dumped.Header, _ = getRedactor().redactHeader(response.Header)
+	dumped.Body = nil
+	head, err := httputil.DumpResponse(&dumped, false)
+	if err != nil {
+		return "", err
+	}
+	return string(head) + dumpBody(body, response.Header.Get("Content-Type"), maxBodyBytes), nil
+}
+
+// dumpBody body超过maxBytes时只读取了部分, 无法按json规则脱敏, 此时有json脱敏规则的json只打印长度
+func dumpBody(body []byte, contentType string, maxBytes int) string {
+	if maxBytes <= 0 || len(body) <= maxBytes {
+		return logBody(body, contentType, maxBytes)
+	}
+	redactor := getRedactor()
+	_, isJSON := codecOrJSON(contentType).(jsonCodec)
+	if isBinaryContentType(contentType) || (isJSON && (len(redactor.jsonPaths) > 0 || len(redactor.jsonKeys) > 0)) {
+		return fmt.Sprintf("<truncated body, %s, more than %d bytes>", contentType, maxBytes)
+	}
+	end := maxBytes
+	// 不截断多字节字符
+	for end > 0 && !utf8.RuneStart(body[end]) {
+		end--
+	}
+	if !utf8.Valid(body[:end]) {
+		return fmt.Sprintf("<truncated body, %s, more than %d bytes>", contentType, maxBytes)
+	}
+	return fmt.Sprintf("%s... (truncated)", body[:end])
+}
+
+// prefixedBody 已读取的部分加上未读取的原响应体, 关闭时关闭原响应体
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// CurlCommand 生成与request等价的curl命令, 用于复现请求, 请求头不会被隐藏, 不会消耗请求体
+func CurlCommand(request *http.Request) (string, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return "", err
+	}
+
+	command := []string{"curl"}
+	if request.Method != "" && request.Method != http.MethodGet {
+		command = append(command, "-X", request.Method)
+	}
+	command = append(command, shellQuote(request.URL.String()))
+
+	names := make([]string, 0, len(request.Header))
+	for name := range request.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range request.Header[name] {
+			command = append(command, "-H", shellQuote(name+": "+value))
+		}
+	}
+	if request.Host != "" && request.Host != request.URL.Host {
+		command = append(command, "-H", shellQuote("Host: "+request.Host))
+	}
+	if len(body) > 0 {
+		command = append(command, "--data-binary", shellQuote(string(body)))
+	}
+	return strings.Join(command, " "), nil
+}
+
+// shellQuote 用单引号包裹, 内部的单引号会被转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dumpTransport 记录实际发出的请求和收到的响应(包括重试), 由request统一打印, 保证日志的调用位置正确
+type dumpTransport struct {
+	next         http.RoundTripper
+	maxBodyBytes int
+	lock         sync.Mutex
+	dumps        []string
+}
+
+func (t *dumpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if dumped, err := dumpRequest(request, t.maxBodyBytes); err == nil {
+		t.add(dumped)
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	maxBodyBytes := t.maxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultDumpBodyBytes
+	}
+	dumped, err := dumpResponse(response, maxBodyBytes)
+	if err != nil {
+		// 读取了部分响应体, 不能再当作完整的响应返回
+		response.Body.Close()
+		return nil, err
+	}
+	t.add(dumped)
+	return response, nil
+}
+
+func (t *dumpTransport) add(dumped string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.dumps = append(t.dumps, dumped)
+}
+
+// flush 返回并清空记录
+func (t *dumpTransport) flush() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	dumps := t.dumps
+	t.dumps = nil
+	return dumps
+}
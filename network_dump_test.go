@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpRequest(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "https://example.com/users?id=1", strings.NewReader(`{"name":"shen"}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	request.Header.Set("Cookie", "session=secret")
+	request.Header.Set("X-Request-Id", "abc")
+
+	dumped, err := DumpRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{"POST /users?id=1 HTTP/1.1", "Host: example.com", "Authorization: ***", "Cookie: ***", "X-Request-Id: abc", `{"name":"shen"}`} {
+		if !strings.Contains(dumped, expect) {
+			t.Errorf("missing %q in\n%s", expect, dumped)
+		}
+	}
+	if strings.Contains(dumped, "secret") {
+		t.Error(dumped)
+	}
+
+	// 原请求的请求头和请求体不受影响
+	if request.Header.Get("Authorization") != "Bearer secret" {
+		t.Error(request.Header)
+	}
+	body, _ := io.ReadAll(request.Body)
+	if string(body) != `{"name":"shen"}` {
+		t.Error(string(body))
+	}
+}
+
+func TestDumpResponse(t *testing.T) {
+	response := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Set-Cookie": {"session=secret"}, "Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("hello")),
+	}
+	dumped, err := DumpResponse(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dumped, "Set-Cookie: ***") || !strings.HasSuffix(dumped, "hello") || strings.Contains(dumped, "secret") {
+		t.Error(dumped)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != "hello" {
+		t.Error(string(body))
+	}
+}
+
+func TestCurlCommand(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPut, "https://example.com/a?b=1&c=2", strings.NewReader(`{"name":"it's"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer token")
+
+	command, err := CurlCommand(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `curl -X PUT 'https://example.com/a?b=1&c=2' -H 'Authorization: Bearer token' -H 'Content-Type: application/json' --data-binary '{"name":"it'\''s"}'`
+	if command != expect {
+		t.Errorf("\n%s\n%s", command, expect)
+	}
+
+	request, _ = http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if command, _ = CurlCommand(request); command != "curl 'https://example.com/'" {
+		t.Error(command)
+	}
+}
+
+func TestNetLogDump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	transport := &dumpTransport{next: http.DefaultTransport}
+	client := &http.Client{Transport: transport}
+	request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"id":1}`))
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != `{"id":1}` {
+		t.Error(string(body))
+	}
+
+	dumps := transport.flush()
+	if len(dumps) != 2 || !strings.Contains(dumps[0], "Authorization: ***") || !strings.HasSuffix(dumps[1], `{"id":1}`) {
+		t.Error(dumps)
+	}
+	if len(transport.flush()) != 0 {
+		t.Error("flush should clear dumps")
+	}
+
+	user := new(User)
+	err = Post(server.URL, &User{ID: 1, Title: "dump"}, user, NetLogLevelOption(NetLogURL|NetLogDump|NetLogError))
+	if err != nil || user.Title != "dump" {
+		t.Error(err, user)
+	}
+}
+
+// failingReader 读取data后返回err
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestDumpTransportBody(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	transport := &dumpTransport{maxBodyBytes: 10, next: transportFunc(func(request *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"Content-Type": {"text/plain"}},
+			Body: io.NopCloser(strings.NewReader(body)), Request: request}, nil
+	})}
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只读取了maxBodyBytes+1字节用于输出, 响应体仍然完整
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(data) != body {
+		t.Error(string(data))
+	}
+	dumps := transport.flush()
+	if len(dumps) != 2 || !strings.HasSuffix(dumps[1], "\r\n\r\naaaaaaaaaa... (truncated)") {
+		t.Error(dumps)
+	}
+
+	// 读取出错时返回错误, 不返回截断的响应体
+	readErr := errors.New("connection reset")
+	transport.next = transportFunc(func(request *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{},
+			Body: io.NopCloser(&failingReader{data: []byte("abc"), err: readErr})}, nil
+	})
+	if response, err = transport.RoundTrip(request); !errors.Is(err, readErr) || response != nil {
+		t.Error(err, response)
+	}
+
+	// 有json脱敏规则时不打印截断的json
+	SetRedaction(RedactJSONKeys("password"))
+	defer SetRedaction()
+	if dumped := dumpBody([]byte(`{"password":"secret"}`), "application/json", 10); strings.Contains(dumped, "secret") {
+		t.Error(dumped)
+	}
+}
+
+func TestNetLogDumpMaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不设置Content-Length, 只能在读取时发现超过限制
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	defer server.Close()
+
+	var tooLarge *ResponseTooLargeError
+	err := Get(server.URL, nil, new(string), NetLogLevelOption(NetLogDump), NetMaxLogBytes(10), NetMaxResponseSize(100))
+	if !errors.As(err, &tooLarge) {
+		t.Error(err)
+	}
+}
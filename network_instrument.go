@@ -0,0 +1,459 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestInfo 请求开始时的信息
+type RequestInfo struct {
+	Method string
+	Host   string
+	Route  string // 路由模板, NetRoute设置的值, 或PathParams替换前的path(eg: /users/{id}), 否则为RouteOther
+	URL    string
+}
+
+// RouteOther 既没有NetRoute也没有PathParams时的路由, 避免path中的id等导致指标的label无限增长,
+// 需要按接口统计时通过NetRoute设置
+const RouteOther = "other"
+
+// RequestStats 请求结束时的信息
+type RequestStats struct {
+	RequestInfo
+	StatusCode    int   // 没有收到响应时为0
+	RequestBytes  int64 // 请求体的字节数, 未知时为-1
+	ResponseBytes int64 // 读取的响应体字节数, obj为*http.Response时为Content-Length(未知时为-1)
+	Duration      time.Duration
+	Retries       int // 同一个请求实际发出的次数减1, 如收到401刷新token后的重试, 重定向不算
+	Err           error
+}
+
+// Instrumenter 请求埋点, 用于对接metrics/tracing, 不依赖具体的SDK
+type Instrumenter interface {
+	// Start 请求开始时调用, 返回的context会作为请求的context, 可以在其中保存span等信息,
+	// 通过ContextWithTraceParent设置的TraceParent会作为traceparent请求头发送
+	Start(ctx context.Context, info *RequestInfo) context.Context
+	// End 请求结束时调用, ctx为Start返回的context
+	End(ctx context.Context, stats *RequestStats)
+}
+
+type instrumenterHolder struct {
+	instrumenter Instrumenter
+}
+
+var defaultInstrumenter atomic.Value // instrumenterHolder
+
+// SetInstrumenter 设置全局埋点, 传nil关闭, 单次请求可以通过NetInstrumenter覆盖, 并发安全
+func SetInstrumenter(instrumenter Instrumenter) {
+	defaultInstrumenter.Store(instrumenterHolder{instrumenter: instrumenter})
+}
+
+// GetInstrumenter SetInstrumenter设置的埋点, 没有设置时为nil
+func GetInstrumenter() Instrumenter {
+	holder, _ := defaultInstrumenter.Load().(instrumenterHolder)
+	return holder.instrumenter
+}
+
+type multiInstrumenter []Instrumenter
+
+// MultiInstrumenter 组合多个Instrumenter, Start按顺序调用, End按相反的顺序调用
+func MultiInstrumenter(instrumenters ...Instrumenter) Instrumenter {
+	return multiInstrumenter(instrumenters)
+}
+
+func (m multiInstrumenter) Start(ctx context.Context, info *RequestInfo) context.Context {
+	for _, instrumenter := range m {
+		ctx = instrumenter.Start(ctx, info)
+	}
+	return ctx
+}
+
+func (m multiInstrumenter) End(ctx context.Context, stats *RequestStats) {
+	for i := len(m) - 1; i >= 0; i-- {
+		m[i].End(ctx, stats)
+	}
+}
+
+// instrumentation 一次请求的埋点状态
+type instrumentation struct {
+	instrumenter Instrumenter
+	ctx          context.Context
+	start        time.Time
+	stats        RequestStats
+	attempts     *attemptTransport
+}
+
+// startInstrumentation 没有配置Instrumenter时返回nil, rawURL为PathParams替换前的url
+func startInstrumentation(ctx context.Context, config *httpConfig, rawURL string) *instrumentation {
+	instrumenter := config.Instrumenter
+	if instrumenter == nil {
+		instrumenter = GetInstrumenter()
+	}
+	if instrumenter == nil {
+		return nil
+	}
+	info := RequestInfo{Method: config.Method, Route: config.Route, URL: config.URL}
+	if u, err := url.Parse(config.URL); err == nil {
+		info.Host = u.Host
+	}
+	// PathParams替换前的path即为路由模板
+	if info.Route == "" && len(config.PathParams) > 0 {
+		if u, err := url.Parse(rawURL); err == nil {
+			info.Route = u.Path
+		}
+	}
+	if info.Route == "" {
+		info.Route = RouteOther
+	}
+	probe := &instrumentation{instrumenter: instrumenter, start: time.Now()}
+	probe.stats.RequestInfo = info
+	probe.stats.RequestBytes = -1
+	probe.ctx = instrumenter.Start(ctx, &probe.stats.RequestInfo)
+	return probe
+}
+
+func (i *instrumentation) end(err error) {
+	i.stats.Duration = time.Since(i.start)
+	i.stats.Retries = i.attempts.retries()
+	i.stats.Err = err
+	i.instrumenter.End(i.ctx, &i.stats)
+}
+
+// attemptTransport 记录同一个url实际发出请求的次数, 重定向到其他url时重新计数
+type attemptTransport struct {
+	next     http.RoundTripper
+	lock     sync.Mutex
+	url      string
+	attempts int
+}
+
+func (t *attemptTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	if u := request.URL.String(); u != t.url {
+		t.url, t.attempts = u, 0
+	}
+	t.attempts++
+	t.lock.Unlock()
+	return t.next.RoundTrip(request)
+}
+
+// retries 最后一个url重试的次数, nil时为0
+func (t *attemptTransport) retries() int {
+	if t == nil {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.attempts > 1 {
+		return t.attempts - 1
+	}
+	return 0
+}
+
+// TraceParent W3C Trace Context的traceparent, https://www.w3.org/TR/trace-context/
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte // 01: sampled
+}
+
+// NewTraceParent 生成新的trace, sampled
+func NewTraceParent() TraceParent {
+	tp := TraceParent{Flags: 1}
+	rand.Read(tp.TraceID[:])
+	rand.Read(tp.SpanID[:])
+	return tp
+}
+
+// ParseTraceParent 解析traceparent请求头, eg: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceParent(value string) (TraceParent, error) {
+	var tp TraceParent
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return tp, fmt.Errorf("invalid traceparent %q", value)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return tp, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err = hex.Decode(tp.TraceID[:], []byte(parts[1])); err != nil {
+		return tp, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err = hex.Decode(tp.SpanID[:], []byte(parts[2])); err != nil {
+		return tp, fmt.Errorf("invalid traceparent %q", value)
+	}
+	tp.Flags = flags[0]
+	if !tp.IsValid() {
+		return tp, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return tp, nil
+}
+
+// IsValid trace id和span id都不为0
+func (tp TraceParent) IsValid() bool {
+	return tp.TraceID != [16]byte{} && tp.SpanID != [8]byte{}
+}
+
+// Child 同一个trace下新的span
+func (tp TraceParent) Child() TraceParent {
+	child := TraceParent{TraceID: tp.TraceID, Flags: tp.Flags}
+	rand.Read(child.SpanID[:])
+	return child
+}
+
+func (tp TraceParent) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tp.TraceID[:]), hex.EncodeToString(tp.SpanID[:]), tp.Flags)
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent 请求使用该ctx时会发送traceparent请求头
+func ContextWithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, tp)
+}
+
+// TraceParentFromContext 获取ContextWithTraceParent设置的TraceParent
+func TraceParentFromContext(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(traceParentKey{}).(TraceParent)
+	return tp, ok && tp.IsValid()
+}
+
+// TraceSpan TraceInstrumenter记录的一次请求
+type TraceSpan struct {
+	TraceParent TraceParent // 该请求的span, 通过traceparent请求头发送
+	Parent      TraceParent // 上级span, 没有时为零值
+	Start       time.Time
+	Stats       *RequestStats
+}
+
+type traceSpanKey struct{}
+
+type traceInstrumenter struct {
+	onEnd func(span *TraceSpan)
+}
+
+// TraceInstrumenter 为每个请求生成span并通过traceparent请求头传递, ctx中有TraceParent时作为上级span,
+// 请求结束时回调onEnd(可以为nil), 可用于导出到任意tracing系统
+func TraceInstrumenter(onEnd func(span *TraceSpan)) Instrumenter {
+	return &traceInstrumenter{onEnd: onEnd}
+}
+
+func (t *traceInstrumenter) Start(ctx context.Context, info *RequestInfo) context.Context {
+	span := &TraceSpan{Start: time.Now()}
+	if parent, ok := TraceParentFromContext(ctx); ok {
+		span.Parent = parent
+		span.TraceParent = parent.Child()
+	} else {
+		span.TraceParent = NewTraceParent()
+	}
+	ctx = context.WithValue(ctx, traceSpanKey{}, span)
+	return ContextWithTraceParent(ctx, span.TraceParent)
+}
+
+func (t *traceInstrumenter) End(ctx context.Context, stats *RequestStats) {
+	span, ok := ctx.Value(traceSpanKey{}).(*TraceSpan)
+	if !ok || t.onEnd == nil {
+		return
+	}
+	span.Stats = stats
+	t.onEnd(span)
+}
+
+// MetricsOptionFunc NewPrometheusMetrics配置
+type MetricsOptionFunc func(o *metricsOptions)
+
+type metricsOptions struct {
+	Namespace string    // 指标名前缀, eg: myapp -> myapp_http_client_requests_total
+	Buckets   []float64 // 耗时直方图的桶(秒), default: 同prometheus.DefBuckets
+}
+
+// MetricsNamespace 指标名前缀
+func MetricsNamespace(namespace string) MetricsOptionFunc {
+	return func(o *metricsOptions) {
+		o.Namespace = namespace
+	}
+}
+
+// MetricsBuckets 耗时直方图的桶(秒), 从小到大
+func MetricsBuckets(buckets ...float64) MetricsOptionFunc {
+	return func(o *metricsOptions) {
+		o.Buckets = buckets
+	}
+}
+
+type metricKey struct {
+	method string
+	host   string
+	route  string
+	status string
+}
+
+type metricSeries struct {
+	requests      uint64
+	errors        uint64
+	retries       uint64
+	requestBytes  int64
+	responseBytes int64
+	durationSum   float64
+	buckets       []uint64
+}
+
+// PrometheusMetrics 按method/host/route/status统计请求数, 错误数, 重试次数, 字节数和耗时,
+// 以Prometheus文本格式输出, 实现了Instrumenter和http.Handler, 并发安全
+type PrometheusMetrics struct {
+	options metricsOptions
+	lock    sync.Mutex
+	series  map[metricKey]*metricSeries
+}
+
+// NewPrometheusMetrics 创建PrometheusMetrics, 通过SetInstrumenter/NetInstrumenter使用
+func NewPrometheusMetrics(options ...MetricsOptionFunc) *PrometheusMetrics {
+	o := metricsOptions{Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}}
+	for _, option := range options {
+		option(&o)
+	}
+	return &PrometheusMetrics{options: o, series: make(map[metricKey]*metricSeries)}
+}
+
+// Start Instrumenter
+func (m *PrometheusMetrics) Start(ctx context.Context, info *RequestInfo) context.Context {
+	return ctx
+}
+
+// End Instrumenter
+func (m *PrometheusMetrics) End(ctx context.Context, stats *RequestStats) {
+	key := metricKey{method: stats.Method, host: stats.Host, route: stats.Route, status: "none"}
+	if stats.StatusCode > 0 {
+		key.status = strconv.Itoa(stats.StatusCode)
+	}
+	seconds := stats.Duration.Seconds()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	series, ok := m.series[key]
+	if !ok {
+		series = &metricSeries{buckets: make([]uint64, len(m.options.Buckets))}
+		m.series[key] = series
+	}
+	series.requests++
+	if stats.Err != nil {
+		series.errors++
+	}
+	series.retries += uint64(stats.Retries)
+	if stats.RequestBytes > 0 {
+		series.requestBytes += stats.RequestBytes
+	}
+	if stats.ResponseBytes > 0 {
+		series.responseBytes += stats.ResponseBytes
+	}
+	series.durationSum += seconds
+	for i, bucket := range m.options.Buckets {
+		if seconds <= bucket {
+			series.buckets[i]++
+		}
+	}
+}
+
+// WriteTo 以Prometheus文本格式输出
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.lock.Lock()
+	keys := make([]metricKey, 0, len(m.series))
+	snapshot := make(map[metricKey]metricSeries, len(m.series))
+	for key, series := range m.series {
+		keys = append(keys, key)
+		copied := *series
+		copied.buckets = append([]uint64(nil), series.buckets...)
+		snapshot[key] = copied
+	}
+	m.lock.Unlock()
+	sort.Slice(keys, func(i, k int) bool {
+		a, b := keys[i], keys[k]
+		if a.host != b.host {
+			return a.host < b.host
+		}
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	name := "http_client_"
+	if m.options.Namespace != "" {
+		name = m.options.Namespace + "_" + name
+	}
+	buf := new(strings.Builder)
+	counter := func(metric string, help string, value func(series metricSeries) string) {
+		fmt.Fprintf(buf, "# HELP %s%s %s\n# TYPE %s%s counter\n", name, metric, help, name, metric)
+		for _, key := range keys {
+			fmt.Fprintf(buf, "%s%s{%s} %s\n", name, metric, key.labels(), value(snapshot[key]))
+		}
+	}
+	counter("requests_total", "Total number of HTTP client requests.", func(series metricSeries) string {
+		return strconv.FormatUint(series.requests, 10)
+	})
+	counter("request_errors_total", "Total number of HTTP client requests that returned an error.", func(series metricSeries) string {
+		return strconv.FormatUint(series.errors, 10)
+	})
+	counter("request_retries_total", "Total number of HTTP client request retries.", func(series metricSeries) string {
+		return strconv.FormatUint(series.retries, 10)
+	})
+	counter("request_bytes_total", "Total bytes of HTTP client request bodies.", func(series metricSeries) string {
+		return strconv.FormatInt(series.requestBytes, 10)
+	})
+	counter("response_bytes_total", "Total bytes of HTTP client response bodies.", func(series metricSeries) string {
+		return strconv.FormatInt(series.responseBytes, 10)
+	})
+
+	metric := name + "request_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s HTTP client request duration in seconds.\n# TYPE %s histogram\n", metric, metric)
+	for _, key := range keys {
+		series := snapshot[key]
+		labels := key.labels()
+		for i, bucket := range m.options.Buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", metric, labels, strconv.FormatFloat(bucket, 'g', -1, 64), series.buckets[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", metric, labels, series.requests)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", metric, labels, strconv.FormatFloat(series.durationSum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", metric, labels, series.requests)
+	}
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
+
+// ServeHTTP 输出指标, 可以直接挂载到/metrics
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Reset 清空已统计的指标
+func (m *PrometheusMetrics) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.series = make(map[metricKey]*metricSeries)
+}
+
+func (k metricKey) labels() string {
+	return fmt.Sprintf(`method="%s",host="%s",route="%s",status="%s"`,
+		escapeLabel(k.method), escapeLabel(k.host), escapeLabel(k.route), escapeLabel(k.status))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// statsRecorder 记录End收到的RequestStats
+type statsRecorder struct {
+	lock  sync.Mutex
+	stats []RequestStats
+}
+
+func (r *statsRecorder) Start(ctx context.Context, info *RequestInfo) context.Context {
+	return ctx
+}
+
+func (r *statsRecorder) End(ctx context.Context, stats *RequestStats) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats = append(r.stats, *stats)
+}
+
+// invalidatingTokenSource 收到401后换一个token重试一次
+type invalidatingTokenSource struct {
+	version int32
+}
+
+func (s *invalidatingTokenSource) Token(context.Context) (*Token, error) {
+	return &Token{AccessToken: fmt.Sprint("token", atomic.LoadInt32(&s.version))}, nil
+}
+
+func (s *invalidatingTokenSource) Invalidate(*Token) {
+	atomic.AddInt32(&s.version, 1)
+}
+
+func TestInstrumenter(t *testing.T) {
+	var unauthorized int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth" && atomic.AddInt32(&unauthorized, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id":1,"title":"hello"}`)
+	}))
+	defer server.Close()
+
+	recorder := new(statsRecorder)
+	metrics := NewPrometheusMetrics(MetricsNamespace("test"), MetricsBuckets(0.5, 1))
+	SetInstrumenter(MultiInstrumenter(recorder, metrics))
+	defer SetInstrumenter(nil)
+	logNone := NetLogLevelOption(NetLogNone)
+
+	Get(server.URL+"/users/{id}", nil, new(User), logNone, NetPathParams(map[string]string{"id": "1"}))
+	Post(server.URL+"/users", &User{ID: 2}, nil, logNone, NetRoute("/users"))
+	Get(server.URL+"/auth", nil, nil, logNone, NetTokenSource(new(invalidatingTokenSource)))
+	Get(server.URL+"/users/1", nil, new(User), logNone, UnmarshalPath([]interface{}{"title"}))
+	Get("http://127.0.0.1:1/refused", nil, nil, logNone)
+
+	if len(recorder.stats) != 5 {
+		t.Fatal(recorder.stats)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	first := recorder.stats[0]
+	if first.Method != http.MethodGet || first.Host != host || first.Route != "/users/{id}" || first.URL != server.URL+"/users/1" ||
+		first.StatusCode != http.StatusOK || first.RequestBytes != 0 || first.ResponseBytes != 24 || first.Err != nil || first.Duration <= 0 {
+		t.Errorf("%+v", first)
+	}
+	if post := recorder.stats[1]; post.Route != "/users" || post.RequestBytes != int64(len(`{"id":2,"user_id":0,"title":"","body":""}`)) {
+		t.Errorf("%+v", post)
+	}
+	if retried := recorder.stats[2]; retried.Retries != 1 || retried.StatusCode != http.StatusOK {
+		t.Errorf("%+v", retried)
+	}
+	if failed := recorder.stats[3]; failed.Err == nil || failed.StatusCode != http.StatusOK {
+		t.Errorf("%+v", failed)
+	}
+	if refused := recorder.stats[4]; refused.Err == nil || refused.StatusCode != 0 || refused.Host != "127.0.0.1:1" || refused.Route != RouteOther {
+		t.Errorf("%+v", refused)
+	}
+
+	output := new(strings.Builder)
+	metrics.WriteTo(output)
+	labels := fmt.Sprintf(`method="GET",host="%s",route="/users/{id}",status="200"`, host)
+	for _, line := range []string{
+		"# TYPE test_http_client_requests_total counter",
+		"test_http_client_requests_total{" + labels + "} 1",
+		fmt.Sprintf(`test_http_client_request_retries_total{method="GET",host="%s",route="other",status="200"} 1`, host),
+		fmt.Sprintf(`test_http_client_request_errors_total{method="GET",host="%s",route="other",status="200"} 1`, host),
+		`test_http_client_requests_total{method="GET",host="127.0.0.1:1",route="other",status="none"} 1`,
+		"test_http_client_response_bytes_total{" + labels + "} 24",
+		"# TYPE test_http_client_request_duration_seconds histogram",
+		"test_http_client_request_duration_seconds_bucket{" + labels + `,le="0.5"} 1`,
+		"test_http_client_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 1`,
+		"test_http_client_request_duration_seconds_count{" + labels + "} 1",
+	} {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("missing %s in\n%s", line, output)
+		}
+	}
+
+	response := httptest.NewRecorder()
+	metrics.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(response.Header().Get("Content-Type"), "text/plain") || response.Body.String() != output.String() {
+		t.Error(response.Header())
+	}
+	metrics.Reset()
+	output.Reset()
+	metrics.WriteTo(output)
+	if strings.Contains(output.String(), "} ") {
+		t.Error(output)
+	}
+}
+
+func TestTraceInstrumenter(t *testing.T) {
+	received := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Traceparent")
+	}))
+	defer server.Close()
+
+	var spans []*TraceSpan
+	tracer := TraceInstrumenter(func(span *TraceSpan) {
+		spans = append(spans, span)
+	})
+	logNone := NetLogLevelOption(NetLogNone)
+
+	// 没有上级span时生成新的trace
+	Get(server.URL, nil, nil, logNone, NetInstrumenter(tracer))
+	header := <-received
+	if len(spans) != 1 || header != spans[0].TraceParent.String() || spans[0].Parent.IsValid() || spans[0].Stats.StatusCode != http.StatusOK {
+		t.Fatal(header, spans)
+	}
+
+	parent, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ContextWithTraceParent(context.Background(), parent)
+	Get(server.URL, nil, nil, logNone, NetInstrumenter(tracer), NetContext(ctx))
+	header = <-received
+	child, err := ParseTraceParent(header)
+	if err != nil || child.TraceID != parent.TraceID || child.SpanID == parent.SpanID || spans[1].Parent != parent || spans[1].TraceParent != child {
+		t.Error(err, header)
+	}
+
+	// 没有Instrumenter时原样传递ctx中的traceparent
+	Get(server.URL, nil, nil, logNone, NetContext(ctx))
+	if header = <-received; header != parent.String() {
+		t.Error(header)
+	}
+
+	for _, value := range []string{"", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-xyz-00f067aa0ba902b7-01"} {
+		if _, err = ParseTraceParent(value); err == nil {
+			t.Error(value)
+		}
+	}
+}
+
+func TestSetInstrumenter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+	defer SetInstrumenter(nil)
+	logNone := NetLogLevelOption(NetLogNone)
+
+	recorder := new(statsRecorder)
+	SetInstrumenter(recorder)
+	if GetInstrumenter() != recorder {
+		t.Fatal("expect recorder")
+	}
+
+	// 请求过程中切换全局埋点是并发安全的
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetInstrumenter(recorder)
+		}()
+		go func() {
+			defer wg.Done()
+			Get(server.URL, nil, nil, logNone)
+		}()
+	}
+	wg.Wait()
+	recorder.lock.Lock()
+	count := len(recorder.stats)
+	recorder.lock.Unlock()
+	if count != 10 {
+		t.Fatal(count)
+	}
+	SetInstrumenter(nil)
+	if GetInstrumenter() != nil {
+		t.Fatal("expect nil")
+	}
+}
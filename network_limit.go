@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// ResponseTooLargeError 响应体超过NetMaxResponseSize设置的大小
+type ResponseTooLargeError struct {
+	Limit         int64
+	ContentLength int64 // 响应头中的Content-Length, 未知时为-1
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	if e.ContentLength >= 0 {
+		return fmt.Sprintf("response body too large: %d bytes, limit %d bytes", e.ContentLength, e.Limit)
+	}
+	return fmt.Sprintf("response body too large: more than %d bytes", e.Limit)
+}
+
+// readResponseBody 读取响应体, limit大于0时超过limit返回*ResponseTooLargeError
+func readResponseBody(body io.Reader, contentLength int64, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(body)
+	}
+	if contentLength > limit {
+		return nil, &ResponseTooLargeError{Limit: limit, ContentLength: contentLength}
+	}
+	result, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(result)) > limit {
+		return nil, &ResponseTooLargeError{Limit: limit, ContentLength: contentLength}
+	}
+	return result, nil
+}
+
+// limitedBody obj为*http.Response时使用, 读取超过limit时返回*ResponseTooLargeError
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       *ResponseTooLargeError
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// 确认是否还有更多数据
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, b.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// countingReader 记录读取的字节数
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// binaryContentTypes 不打印内容的Content-Type, 以"/"结尾的为前缀匹配
+var binaryContentTypes = []string{
+	"image/", "audio/", "video/", "font/",
+	"application/octet-stream", "application/pdf", "application/zip", "application/gzip",
+	"application/x-gzip", "application/x-tar", "application/x-protobuf", "application/protobuf", "application/grpc",
+}
+
+func isBinaryContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, binary := range binaryContentTypes {
+		if strings.HasSuffix(binary, "/") && strings.HasPrefix(mediaType, binary) || mediaType == binary {
+			return true
+		}
+	}
+	return false
+}
+
+// logBody 日志中打印的请求体/响应体: 先按SetRedaction的规则脱敏, 二进制内容只打印类型和大小, maxBytes大于0时截断超出的部分
+func logBody(body []byte, contentType string, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if isBinaryContentType(contentType) || !utf8.Valid(body) {
+		return fmt.Sprintf("<binary body, %s, %d bytes>", contentType, len(body))
+	}
+	if redacted, changed := getRedactor().redactJSON(body); changed {
+		body = redacted
+	}
+	if maxBytes <= 0 || len(body) <= maxBytes {
+		return string(body)
+	}
+	end := maxBytes
+	// 不截断多字节字符
+	for end > 0 && !utf8.RuneStart(body[end]) {
+		end--
+	}
+	return fmt.Sprintf("%s... (%d bytes more)", body[:end], len(body)-end)
+}
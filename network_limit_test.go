@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			// 不设置Content-Length
+			w.Write([]byte(body[:50]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(body[50:]))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	for _, query := range []string{"", "?chunked=1"} {
+		var tooLarge *ResponseTooLargeError
+		err := Get(server.URL+query, nil, new(string), NetMaxResponseSize(99), NetLogLevelOption(NetLogNone))
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 99 {
+			t.Error(query, err)
+		}
+
+		response := new(http.Response)
+		err = Get(server.URL+query, nil, response, NetMaxResponseSize(99), NetLogLevelOption(NetLogNone))
+		if query == "" {
+			if !errors.As(err, &tooLarge) || tooLarge.ContentLength != 100 {
+				t.Error(err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if !errors.As(err, &tooLarge) || len(result) != 99 {
+			t.Error(len(result), err)
+		}
+	}
+
+	response := new(http.Response)
+	if err := Get(server.URL+"?chunked=1", nil, response, NetMaxResponseSize(100), NetLogLevelOption(NetLogNone)); err != nil {
+		t.Fatal(err)
+	}
+	result, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil || string(result) != body {
+		t.Error(len(result), err)
+	}
+}
+
+func TestLogBody(t *testing.T) {
+	if result := logBody([]byte("hello world"), "text/plain", 5); result != "hello... (6 bytes more)" {
+		t.Error(result)
+	}
+	if result := logBody([]byte("你好"), "text/plain", 4); result != "你... (3 bytes more)" {
+		t.Error(result)
+	}
+	if result := logBody([]byte("hello"), "text/plain", 0); result != "hello" {
+		t.Error(result)
+	}
+	if result := logBody([]byte("hello"), "image/png", 0); result != "<binary body, image/png, 5 bytes>" {
+		t.Error(result)
+	}
+	if result := logBody([]byte{0xff, 0xfe, 0x00}, "", 0); result != "<binary body, , 3 bytes>" {
+		t.Error(result)
+	}
+
+	// 先脱敏再截断
+	SetRedaction(RedactJSONKeys("password"))
+	defer SetRedaction()
+	if result := logBody([]byte(`{"password":"123456789","name":"shen"}`), "application/json", 20); result != `{"password":"***","n... (12 bytes more)` {
+		t.Error(result)
+	}
+}
+
+func TestDumpMaxBodyBytes(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "https://example.com/", strings.NewReader("0123456789"))
+	dumped, err := dumpRequest(request, 4)
+	if err != nil || !strings.HasSuffix(dumped, "\r\n\r\n0123... (6 bytes more)") || !strings.Contains(dumped, "Content-Length: 10") {
+		t.Errorf("%q %v", dumped, err)
+	}
+}
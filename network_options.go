@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"net/http"
 	"reflect"
 	"time"
@@ -13,13 +14,42 @@ var netOptionType = reflect.TypeOf(NetOptionFunc(nil))
 
 // netOptions 额外配置, 未进行配置的项, 会使用默认值
 type netOptions struct {
-	Header        http.Header
-	NetLogLevel   NetLogLevel   // default: NetLogAllWithoutObj
-	LogCallerSkip int           // default: 0 代表请求位置的方法所跳过的层数,如果想看tools内部打印所在的方法, 可以传-2
-	LogLineSkip   int           // default: 0, 代表请求位置的行号所跳过的层数,如果想看tools内部的打印所在的行, 可以传-2
-	Timeout       time.Duration // 为0会忽略
-	UnmarshalPath []interface{} // 仅当obj参数不为nil时有效, eg:[]interface{}{"a",0,"b"}, 将会解析a下面的第1个元素的b节点
-	contentType   string        // default: "application/json" , post only, 该参数不对外开放, 如有需求可以通过header进行设置.
+	Context          context.Context
+	Header           http.Header
+	NetLogLevel      NetLogLevel       // default: NetLogAllWithoutObj
+	LogCallerSkip    int               // default: 0 代表请求位置的方法所跳过的层数,如果想看tools内部打印所在的方法, 可以传-2
+	LogLineSkip      int               // default: 0, 代表请求位置的行号所跳过的层数,如果想看tools内部的打印所在的行, 可以传-2
+	Timeout          time.Duration     // 为0会忽略
+	UnmarshalPath    []interface{}     // 仅当obj参数不为nil时有效, eg:[]interface{}{"a",0,"b"}, 将会解析a下面的第1个元素的b节点
+	Cache            CacheStore        // 仅对GET请求有效, default: SetCacheStore设置的全局缓存
+	CacheMode        CacheMode         // default: CacheDefault
+	Limiter          Limiter           // default: SetRateLimiter设置的全局限流器
+	LimitFailFast    bool              // 为true时没有可用令牌直接返回ErrRateLimited, 否则等待
+	Breaker          *CircuitBreaker   // default: SetCircuitBreaker设置的全局熔断器
+	CircuitName      string            // 熔断器按该名称区分, default: 请求的host
+	BasicAuth        *[2]string        // [username, password]
+	TokenSource      TokenSource       // 设置Authorization, 收到401时刷新token重试一次
+	Signer           Signer            // 请求签名, 在限流等待之后, 请求真正发出前签名
+	PathParams       map[string]string // 替换URL中的{name}占位符, 会进行path转义
+	Query            interface{}       // struct/map/url.Values, 通过EncodeQuery编码后合并到URL已有的query中
+	Client           *http.Client      // default: http.DefaultClient, 请求时会复制一份, 不会修改传入的client
+	MaxResponseSize  int64             // 响应体的最大字节数, 超过时返回*ResponseTooLargeError, 0不限制
+	MaxLogBytes      int               // 日志中打印的请求体/响应体的最大字节数, 超过的部分会被截断, 0不限制
+	Stream           bool              // 直接从响应体流式解析json到obj, 不读取完整的响应体, 仅对json响应有效
+	Instrumenter     Instrumenter      // default: SetInstrumenter设置的全局埋点
+	Route            string            // 埋点使用的路由模板, default: PathParams替换前的path, 没有PathParams时为RouteOther
+	CompressEncoding string            // 请求体的压缩方式, 为空不压缩
+	CompressMinSize  int               // 请求体达到该字节数才压缩
+	AcceptEncodings  []string          // 为空时由net/http处理gzip, 否则设置Accept-Encoding并解压响应体
+	contentType      string            // default: "application/json" , post only, 该参数不对外开放, 如有需求可以通过header进行设置.
+	response         *Response         // GetResponse等返回的元信息, 不对外开放
+}
+
+// NetContext 请求使用的context, 可用于取消请求
+func NetContext(ctx context.Context) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Context = ctx
+	}
 }
 
 // NetHeader header
@@ -64,6 +94,154 @@ func UnmarshalPath(unmarshalPath []interface{}) NetOptionFunc {
 	}
 }
 
+// NetCache GET请求使用的缓存, 覆盖SetCacheStore设置的全局缓存
+func NetCache(store CacheStore) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Cache = store
+	}
+}
+
+// NetCacheMode 单次请求的缓存策略, CacheBypass: 不使用缓存, CacheRefresh: 强制请求并更新缓存
+func NetCacheMode(mode CacheMode) NetOptionFunc {
+	return func(o *netOptions) {
+		o.CacheMode = mode
+	}
+}
+
+// NetRateLimiter 请求使用的限流器, 覆盖SetRateLimiter设置的全局限流器, 多个请求共用同一个limiter即可共享配额
+func NetRateLimiter(limiter Limiter) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Limiter = limiter
+	}
+}
+
+// NetRateLimitFailFast 没有可用令牌时不等待, 直接返回ErrRateLimited
+func NetRateLimitFailFast() NetOptionFunc {
+	return func(o *netOptions) {
+		o.LimitFailFast = true
+	}
+}
+
+// NetCircuitBreaker 请求使用的熔断器, 覆盖SetCircuitBreaker设置的全局熔断器
+func NetCircuitBreaker(breaker *CircuitBreaker) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Breaker = breaker
+	}
+}
+
+// NetCircuitName 熔断器按name区分状态(如按接口区分), 默认按host区分
+func NetCircuitName(name string) NetOptionFunc {
+	return func(o *netOptions) {
+		o.CircuitName = name
+	}
+}
+
+// NetBasicAuth Basic认证
+func NetBasicAuth(username string, password string) NetOptionFunc {
+	return func(o *netOptions) {
+		o.BasicAuth = &[2]string{username, password}
+	}
+}
+
+// NetBearerToken 固定的Bearer token
+func NetBearerToken(token string) NetOptionFunc {
+	return func(o *netOptions) {
+		o.TokenSource = StaticTokenSource(token)
+	}
+}
+
+// NetTokenSource 从source获取token, eg: NewOAuth2TokenSource, 多个请求共用同一个source即可共享token
+func NetTokenSource(source TokenSource) NetOptionFunc {
+	return func(o *netOptions) {
+		o.TokenSource = source
+	}
+}
+
+// NetSigner 请求签名, eg: &HMACSigner{Key: key}, &SigV4Signer{...}
+func NetSigner(signer Signer) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Signer = signer
+	}
+}
+
+// NetHTTPClient 请求使用的http.Client, eg: 带cookie jar或自定义Transport的client
+func NetHTTPClient(client *http.Client) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Client = client
+	}
+}
+
+// NetPathParams 替换URL中的{name}占位符, eg: Get("https://api.example.com/users/{id}", nil, user, NetPathParams(map[string]string{"id": "1"}))
+func NetPathParams(pathParams map[string]string) NetOptionFunc {
+	return func(o *netOptions) {
+		o.PathParams = pathParams
+	}
+}
+
+// NetQuery query参数, 支持带url tag的struct, 见EncodeQuery
+func NetQuery(query interface{}) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Query = query
+	}
+}
+
+// NetMaxResponseSize 响应体的最大字节数, 超过时返回*ResponseTooLargeError, obj为*http.Response时在读取响应体时返回该错误
+func NetMaxResponseSize(size int64) NetOptionFunc {
+	return func(o *netOptions) {
+		o.MaxResponseSize = size
+	}
+}
+
+// NetMaxLogBytes 日志中打印的响应体(以及NetLogDump的请求体/响应体)的最大字节数, 超过的部分以"... (N bytes more)"代替
+func NetMaxLogBytes(size int) NetOptionFunc {
+	return func(o *netOptions) {
+		o.MaxLogBytes = size
+	}
+}
+
+// NetStream 直接从响应体流式解析json到obj(支持UnmarshalPath), 适用于较大的响应, 此时NetLogResponse只打印响应体的类型和大小.
+// 响应的Content-Type对应的codec不是json时仍然读取完整的响应体
+func NetStream() NetOptionFunc {
+	return func(o *netOptions) {
+		o.Stream = true
+	}
+}
+
+// NetInstrumenter 请求使用的埋点, 覆盖SetInstrumenter设置的全局埋点
+func NetInstrumenter(instrumenter Instrumenter) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Instrumenter = instrumenter
+	}
+}
+
+// NetRoute 埋点使用的路由模板, 用于按接口聚合指标, eg: "/users/{id}".
+// 没有设置也没有PathParams时为RouteOther, 不会使用请求的path, 避免path中的id导致label无限增长
+func NetRoute(route string) NetOptionFunc {
+	return func(o *netOptions) {
+		o.Route = route
+	}
+}
+
+// NetCompressRequest json请求体达到minSize字节时按encoding压缩并设置Content-Encoding, 支持EncodingGzip/EncodingDeflate/EncodingZstd/EncodingBrotli,
+// multipart/表单等其他类型的请求体不会压缩
+func NetCompressRequest(encoding string, minSize int) NetOptionFunc {
+	return func(o *netOptions) {
+		o.CompressEncoding = encoding
+		o.CompressMinSize = minSize
+	}
+}
+
+// NetAcceptEncoding 按顺序设置Accept-Encoding并解压响应体, 日志中会打印压缩前后的大小,
+// 不传参数时为br, zstd, gzip, deflate. 不设置时由net/http透明处理gzip
+func NetAcceptEncoding(encodings ...string) NetOptionFunc {
+	return func(o *netOptions) {
+		if len(encodings) == 0 {
+			encodings = defaultAcceptEncodings
+		}
+		o.AcceptEncodings = encodings
+	}
+}
+
 // // ContentType default: "application/json" , post only
 // func ContentType(contentType string) NetOptionFunc {
 // 	return func(o *netOptions) {
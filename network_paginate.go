@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrStopPaginate Paginate的回调返回该错误时停止翻页, Paginate返回nil
+var ErrStopPaginate = errors.New("stop paginate")
+
+// Page 分页请求中的一页
+type Page struct {
+	Number int         // 从1开始, 请求第一页之前为0
+	URL    string      // 该页请求的url
+	Header http.Header // 该页的响应头
+	Body   []byte      // 该页的响应体
+	Items  int         // 该页解析出的元素个数
+}
+
+// PageStrategy 分页方式
+type PageStrategy interface {
+	// Next 根据当前页返回下一页的url, 返回""代表没有下一页. page.Number为0时page.URL为初始url, 返回第一页的url
+	Next(page *Page) (string, error)
+}
+
+// PageStrategyFunc 函数形式的PageStrategy
+type PageStrategyFunc func(page *Page) (string, error)
+
+// Next PageStrategy
+func (f PageStrategyFunc) Next(page *Page) (string, error) {
+	return f(page)
+}
+
+// PageByLink 按响应头中的Link: <url>; rel="next"翻页, 没有next时结束
+func PageByLink() PageStrategy {
+	return PageStrategyFunc(func(page *Page) (string, error) {
+		if page.Number == 0 {
+			return page.URL, nil
+		}
+		next, ok := parseLinkHeader(page.Header)["next"]
+		if !ok {
+			return "", nil
+		}
+		base, err := url.Parse(page.URL)
+		if err != nil {
+			return "", err
+		}
+		nextURL, err := base.Parse(next)
+		if err != nil {
+			return "", err
+		}
+		return nextURL.String(), nil
+	})
+}
+
+// PageByCursor 从响应体path所指的节点读取游标, 作为下一页的param参数, 游标不存在或为空时结束
+// eg: PageByCursor("cursor", "meta", "next_cursor")
+func PageByCursor(param string, path ...interface{}) PageStrategy {
+	return PageStrategyFunc(func(page *Page) (string, error) {
+		if page.Number == 0 {
+			return page.URL, nil
+		}
+		cursor := jsoniter.Get(page.Body, path...)
+		if cursor.ValueType() == jsoniter.InvalidValue || cursor.ValueType() == jsoniter.NilValue {
+			return "", nil
+		}
+		value := cursor.ToString()
+		if value == "" {
+			return "", nil
+		}
+		return setQueryValue(page.URL, param, value)
+	})
+}
+
+// PageByNumber 按页码翻页, 第一页的页码为start, eg: PageByNumber("page", 1)
+func PageByNumber(param string, start int) PageStrategy {
+	return PageStrategyFunc(func(page *Page) (string, error) {
+		return setQueryValue(page.URL, param, strconv.Itoa(start+page.Number))
+	})
+}
+
+// PageByOffset 按offset/limit翻页, offset从初始url中的值开始(没有时为0), 元素个数少于limit时结束
+// eg: PageByOffset("offset", "limit", 100)
+func PageByOffset(offsetParam string, limitParam string, limit int) PageStrategy {
+	return PageStrategyFunc(func(page *Page) (string, error) {
+		u, err := url.Parse(page.URL)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		offset, _ := strconv.Atoi(query.Get(offsetParam))
+		if page.Number > 0 {
+			if page.Items < limit {
+				return "", nil
+			}
+			offset += page.Items
+		}
+		query.Set(offsetParam, strconv.Itoa(offset))
+		query.Set(limitParam, strconv.Itoa(limit))
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	})
+}
+
+// PageOptionFunc Paginate配置
+type PageOptionFunc func(o *pageOptions)
+
+type pageOptions struct {
+	NetOptions []NetOptionFunc
+	ItemsPath  []interface{} // 元素数组所在的路径, default: 响应体本身
+	MaxPages   int           // 最多请求的页数, 0不限制
+}
+
+// PageNetOptions 每一页请求使用的配置, 如请求头/鉴权/client等
+func PageNetOptions(options ...NetOptionFunc) PageOptionFunc {
+	return func(o *pageOptions) {
+		o.NetOptions = append(o.NetOptions, options...)
+	}
+}
+
+// PageItemsPath 元素数组在响应体中的路径, 同UnmarshalPath, eg: PageItemsPath("data", "items")
+func PageItemsPath(path ...interface{}) PageOptionFunc {
+	return func(o *pageOptions) {
+		o.ItemsPath = path
+	}
+}
+
+// PageMaxPages 最多请求的页数, 达到后停止翻页, Paginate返回nil
+func PageMaxPages(maxPages int) PageOptionFunc {
+	return func(o *pageOptions) {
+		o.MaxPages = maxPages
+	}
+}
+
+// PageStatusError 分页请求返回了非2xx的状态码
+type PageStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *PageStatusError) Error() string {
+	return fmt.Sprintf("paginate: unexpected status %d, url %s", e.StatusCode, e.URL)
+}
+
+// Paginate 从urlStr开始按strategy逐页请求, 每一页的元素逐个解析为T并回调fn, fn处理完当前页的元素后才会请求下一页.
+// 没有下一页, 页面为空, 或达到PageMaxPages时结束并返回nil; fn返回错误时停止并返回该错误, 返回ErrStopPaginate时返回nil
+func Paginate[T any](ctx context.Context, urlStr string, strategy PageStrategy, fn func(item T) error, options ...PageOptionFunc) error {
+	o := pageOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+
+	page := &Page{URL: urlStr}
+	next, err := strategy.Next(page)
+	for err == nil && next != "" {
+		if o.MaxPages > 0 && page.Number >= o.MaxPages {
+			return nil
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if page, err = o.fetch(ctx, next, page.Number+1); err != nil {
+			return err
+		}
+		if page.Items, err = decodePage(page, o.ItemsPath, fn); err != nil || page.Items == 0 {
+			break
+		}
+		next, err = strategy.Next(page)
+		// 下一页与当前页相同时结束, 避免死循环
+		if next == page.URL {
+			break
+		}
+	}
+	if err == ErrStopPaginate {
+		return nil
+	}
+	return err
+}
+
+func (o *pageOptions) fetch(ctx context.Context, urlStr string, number int) (*Page, error) {
+	options := append([]NetOptionFunc{LogCallerSkipOption(-2), LogLineSkipOption(-2)}, o.NetOptions...)
+	config := configWithOptions(append(options, NetContext(ctx))...)
+	config.Method = http.MethodGet
+	config.URL = urlStr
+
+	response := new(http.Response)
+	if err := request(response, config); err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, &PageStatusError{URL: urlStr, StatusCode: response.StatusCode}
+	}
+	// MaxResponseSize已经由request限制
+	body, err := readResponseBody(response.Body, response.ContentLength, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Number: number, URL: urlStr, Header: response.Header, Body: body}, nil
+}
+
+// decodePage 解析page中的元素并回调fn, 元素数组不存在或为null时为空页
+func decodePage[T any](page *Page, path []interface{}, fn func(item T) error) (count int, err error) {
+	if len(bytes.TrimSpace(page.Body)) == 0 {
+		return 0, nil
+	}
+	if len(path) > 0 {
+		valueType := jsoniter.Get(page.Body, path...).ValueType()
+		if valueType == jsoniter.InvalidValue || valueType == jsoniter.NilValue {
+			return 0, nil
+		}
+	}
+	err = DecodeJSONEach(bytes.NewReader(page.Body), func(item T) error {
+		count++
+		return fn(item)
+	}, path...)
+	return count, err
+}
+
+// setQueryValue 设置urlStr中的query参数key
+func setQueryValue(urlStr string, key string, value string) (string, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// parseLinkHeader 解析RFC 8288的Link响应头, 返回rel到url的映射, eg: <https://a.com/?page=2>; rel="next"
+func parseLinkHeader(header http.Header) map[string]string {
+	links := make(map[string]string)
+	for _, value := range header.Values("Link") {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			end := strings.IndexByte(value, '>')
+			if start < 0 || end < start {
+				break
+			}
+			link := value[start+1 : end]
+			value = value[end+1:]
+			params := value
+			if index := strings.IndexByte(value, '<'); index >= 0 {
+				params, value = value[:index], value[index:]
+			} else {
+				value = ""
+			}
+			params = strings.TrimRight(strings.TrimSpace(params), ",")
+			for _, param := range strings.Split(params, ";") {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				// rel可以有多个值, eg: rel="next last"
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(rel), `"`)) {
+					rel = strings.ToLower(rel)
+					if _, exist := links[rel]; !exist {
+						links[rel] = link
+					}
+				}
+			}
+		}
+	}
+	return links
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pageTestServer 共10个元素, 按不同的分页方式返回
+func pageTestServer(t *testing.T, requests *int32) *httptest.Server {
+	const total = 10
+	items := func(from, to int) string {
+		var ids []string
+		for i := from; i < to && i < total; i++ {
+			ids = append(ids, fmt.Sprintf(`{"id":%d}`, i))
+		}
+		return "[" + strings.Join(ids, ",") + "]"
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		query := r.URL.Query()
+		if query.Get("token") != "abc" {
+			t.Error(r.URL)
+		}
+		switch r.URL.Path {
+		case "/link":
+			page, _ := strconv.Atoi(query.Get("p"))
+			if page < 2 {
+				w.Header().Add("Link", fmt.Sprintf(`</first?token=abc>; rel="first", </link?token=abc&p=%d>; rel="next last"`, page+1))
+			}
+			fmt.Fprint(w, items(page*4, page*4+4))
+		case "/cursor":
+			from, _ := strconv.Atoi(query.Get("cursor"))
+			next := "null"
+			if from+3 < total {
+				next = strconv.Quote(strconv.Itoa(from + 3))
+			}
+			fmt.Fprintf(w, `{"data":{"items":%s},"next":%s}`, items(from, from+3), next)
+		case "/page":
+			page, _ := strconv.Atoi(query.Get("page"))
+			fmt.Fprintf(w, `{"items":%s}`, items((page-1)*3, page*3))
+		case "/offset":
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			fmt.Fprint(w, items(offset, offset+limit))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPaginate(t *testing.T) {
+	var requests int32
+	server := pageTestServer(t, &requests)
+	defer server.Close()
+
+	ctx := context.Background()
+	netOptions := PageNetOptions(NetLogLevelOption(NetLogNone))
+	tests := []struct {
+		path     string
+		strategy PageStrategy
+		options  []PageOptionFunc
+		requests int32
+	}{
+		// 第3页没有next
+		{"/link?token=abc&p=0", PageByLink(), nil, 3},
+		{"/cursor?token=abc", PageByCursor("cursor", "next"), []PageOptionFunc{PageItemsPath("data", "items")}, 4},
+		// 第5页为空页
+		{"/page?token=abc", PageByNumber("page", 1), []PageOptionFunc{PageItemsPath("items")}, 5},
+		// 第3页只有2个元素
+		{"/offset?token=abc", PageByOffset("offset", "limit", 4), nil, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+		var ids []int
+		err := Paginate(ctx, server.URL+test.path, test.strategy, func(user User) error {
+			ids = append(ids, user.ID)
+			return nil
+		}, append(test.options, netOptions)...)
+		if err != nil || fmt.Sprint(ids) != "[0 1 2 3 4 5 6 7 8 9]" || atomic.LoadInt32(&requests) != test.requests {
+			t.Error(test.path, err, ids, requests)
+		}
+	}
+}
+
+func TestPaginateStop(t *testing.T) {
+	var requests int32
+	server := pageTestServer(t, &requests)
+	defer server.Close()
+
+	ctx := context.Background()
+	netOptions := PageNetOptions(NetLogLevelOption(NetLogNone))
+	count := 0
+	collect := func(user User) error {
+		count++
+		return nil
+	}
+	err := Paginate(ctx, server.URL+"/offset?token=abc", PageByOffset("offset", "limit", 3), collect, netOptions, PageMaxPages(2))
+	if err != nil || count != 6 || atomic.LoadInt32(&requests) != 2 {
+		t.Error(err, count, requests)
+	}
+
+	// 回调返回ErrStopPaginate时不再请求下一页
+	atomic.StoreInt32(&requests, 0)
+	err = Paginate(ctx, server.URL+"/offset?token=abc", PageByOffset("offset", "limit", 3), func(user User) error {
+		if user.ID == 1 {
+			return ErrStopPaginate
+		}
+		return nil
+	}, netOptions)
+	if err != nil || atomic.LoadInt32(&requests) != 1 {
+		t.Error(err, requests)
+	}
+
+	stop := errors.New("stop")
+	err = Paginate(ctx, server.URL+"/offset?token=abc", PageByOffset("offset", "limit", 3), func(user User) error { return stop }, netOptions)
+	if err != stop {
+		t.Error(err)
+	}
+
+	var statusErr *PageStatusError
+	err = Paginate(ctx, server.URL+"/missing?token=abc", PageByLink(), collect, netOptions)
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Error(err)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	err = Paginate(cancelCtx, server.URL+"/offset?token=abc", PageByOffset("offset", "limit", 3), func(user User) error {
+		cancel()
+		return nil
+	}, netOptions)
+	if !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://a.com/?page=2>; rel="next", <https://a.com/?page=5>; rel=last`)
+	header.Add("Link", `<https://a.com/?page=1>;rel="prev first"`)
+	links := parseLinkHeader(header)
+	expect := map[string]string{
+		"next":  "https://a.com/?page=2",
+		"last":  "https://a.com/?page=5",
+		"prev":  "https://a.com/?page=1",
+		"first": "https://a.com/?page=1",
+	}
+	if fmt.Sprint(links) != fmt.Sprint(expect) {
+		t.Error(links)
+	}
+}
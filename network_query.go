@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// EncodeQuery 将struct/map编码为url.Values, struct字段使用url tag:
+//
+//	`url:"name"`            参数名, 不设置时使用字段名
+//	`url:"-"`               忽略该字段
+//	`url:"name,omitempty"`  零值时忽略
+//	`url:"name,comma"`      slice用逗号拼接, 默认为重复的参数名(name=a&name=b)
+//	`url:"name,unix"`       time.Time编码为unix秒, 默认为RFC3339, 也可以通过`layout:"2006-01-02"`指定格式
+//
+// 嵌套的struct/map参数名为"parent.child", 匿名嵌入的struct不加前缀, 实现了encoding.TextMarshaler的类型使用MarshalText
+func EncodeQuery(v interface{}) (url.Values, error) {
+	values := url.Values{}
+	if v == nil {
+		return values, nil
+	}
+	if query, ok := v.(url.Values); ok {
+		for key, value := range query {
+			values[key] = append([]string(nil), value...)
+		}
+		return values, nil
+	}
+
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return values, nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct && value.Kind() != reflect.Map {
+		return nil, fmt.Errorf("EncodeQuery: expect struct or map, got %s", value.Type())
+	}
+	err := encodeQueryValue(values, "", value, queryTag{})
+	return values, err
+}
+
+type queryTag struct {
+	omitEmpty bool
+	comma     bool
+	unix      bool
+	layout    string
+}
+
+func encodeQueryValue(values url.Values, name string, value reflect.Value, tag queryTag) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if tag.omitEmpty && value.IsZero() {
+		return nil
+	}
+
+	if value.Type() == timeType && value.CanInterface() {
+		t := value.Interface().(time.Time)
+		switch {
+		case tag.unix:
+			values.Add(name, strconv.FormatInt(t.Unix(), 10))
+		case tag.layout != "":
+			values.Add(name, t.Format(tag.layout))
+		default:
+			values.Add(name, t.Format(time.RFC3339))
+		}
+		return nil
+	}
+	if value.Type().Implements(textMarshalerType) && value.CanInterface() {
+		text, err := value.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		values.Add(name, string(text))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return encodeQueryStruct(values, name, value)
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("EncodeQuery: unsupported map key type %s", value.Type().Key())
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			if err := encodeQueryValue(values, joinQueryName(name, iter.Key().String()), iter.Value(), queryTag{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(name, string(value.Bytes()))
+			return nil
+		}
+		if !tag.comma {
+			for i := 0; i < value.Len(); i++ {
+				if err := encodeQueryValue(values, name, value.Index(i), queryTag{unix: tag.unix, layout: tag.layout}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		items := url.Values{}
+		for i := 0; i < value.Len(); i++ {
+			if err := encodeQueryValue(items, name, value.Index(i), queryTag{unix: tag.unix, layout: tag.layout}); err != nil {
+				return err
+			}
+		}
+		values.Add(name, strings.Join(items[name], ","))
+		return nil
+	case reflect.String:
+		values.Add(name, value.String())
+	case reflect.Bool:
+		values.Add(name, strconv.FormatBool(value.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Add(name, strconv.FormatInt(value.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		values.Add(name, strconv.FormatUint(value.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		values.Add(name, strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits()))
+	default:
+		return fmt.Errorf("EncodeQuery: unsupported type %s for %q", value.Type(), name)
+	}
+	return nil
+}
+
+func encodeQueryStruct(values url.Values, prefix string, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		// 未导出的匿名struct, 其导出字段仍然需要编码
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tagValue := field.Tag.Get("url")
+		if tagValue == "-" {
+			continue
+		}
+
+		parts := strings.Split(tagValue, ",")
+		name := parts[0]
+		tag := queryTag{layout: field.Tag.Get("layout")}
+		for _, option := range parts[1:] {
+			switch option {
+			case "omitempty":
+				tag.omitEmpty = true
+			case "comma":
+				tag.comma = true
+			case "unix":
+				tag.unix = true
+			}
+		}
+
+		fieldValue := value.Field(i)
+		// 匿名嵌入且没有设置参数名的struct不加前缀
+		if field.Anonymous && name == "" {
+			embedded := fieldValue
+			for embedded.Kind() == reflect.Ptr && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded.Type() != timeType {
+				if err := encodeQueryStruct(values, prefix, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if err := encodeQueryValue(values, joinQueryName(prefix, name), fieldValue, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinQueryName(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+var pathParamRegexp = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// BuildURL 拼接URL
+//
+//	base: 基础URL, 可以带path和query, eg: "https://api.example.com/v1?key=xxx"
+//	path: 拼接在base的path之后, 可以为空, 支持{name}占位符, eg: "/users/{id}"
+//	pathParams: 占位符的值, 会进行path转义, base中的占位符也会被替换, 缺少对应的值会返回错误
+//	query: 追加在base已有的query之后, 已有的query保持原样(顺序和转义)
+func BuildURL(base string, path string, pathParams map[string]string, query url.Values) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	if path != "" {
+		path = strings.TrimPrefix(path, "/")
+		rawPath := u.EscapedPath()
+		if !strings.HasSuffix(rawPath, "/") {
+			rawPath += "/"
+		}
+		u.RawPath = rawPath + path
+	} else {
+		u.RawPath = u.EscapedPath()
+	}
+
+	var missing []string
+	rawPath := pathParamRegexp.ReplaceAllStringFunc(u.RawPath, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		// url.Parse会把base中的{}转义
+		value, ok := pathParams[name]
+		if !ok {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return url.PathEscape(value)
+	})
+	rawPath = replaceEscapedPathParams(rawPath, pathParams, &missing)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("BuildURL: missing path params %v", missing)
+	}
+	if u.Path, err = url.PathUnescape(rawPath); err != nil {
+		return "", err
+	}
+	u.RawPath = rawPath
+
+	return appendQuery(u.String(), query), nil
+}
+
+var escapedPathParamRegexp = regexp.MustCompile(`%7B([^/]+?)%7D`)
+
+// replaceEscapedPathParams 替换被url.Parse转义过的占位符
+func replaceEscapedPathParams(rawPath string, pathParams map[string]string, missing *[]string) string {
+	return escapedPathParamRegexp.ReplaceAllStringFunc(rawPath, func(placeholder string) string {
+		name := placeholder[3 : len(placeholder)-3]
+		value, ok := pathParams[name]
+		if !ok {
+			*missing = append(*missing, name)
+			return placeholder
+		}
+		return url.PathEscape(value)
+	})
+}
+
+// appendQuery 将values追加到urlStr已有的query之后, 已有的query保持原样(顺序和转义), 不会破坏预签名url的签名
+func appendQuery(urlStr string, values url.Values) string {
+	if len(values) == 0 {
+		return urlStr
+	}
+	fragment := ""
+	if i := strings.Index(urlStr, "#"); i >= 0 {
+		urlStr, fragment = urlStr[:i], urlStr[i:]
+	}
+	separator := "?"
+	if i := strings.Index(urlStr, "?"); i >= 0 {
+		separator = "&"
+		if i == len(urlStr)-1 || strings.HasSuffix(urlStr, "&") {
+			separator = ""
+		}
+	}
+	return urlStr + separator + values.Encode() + fragment
+}
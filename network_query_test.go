@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type queryPage struct {
+	Page int `url:"page,omitempty"`
+	Size int `url:"size"`
+}
+
+type queryFilter struct {
+	queryPage
+	Keyword string                `url:"q"`
+	Tags    []string              `url:"tag"`
+	IDs     []int                 `url:"ids,comma"`
+	Since   time.Time             `url:"since" layout:"2006-01-02"`
+	Until   *time.Time            `url:"until,unix"`
+	Owner   struct{ Name string } `url:"owner"`
+	Extra   map[string]string     `url:"extra"`
+	Secret  string                `url:"-"`
+	Empty   string                `url:"empty,omitempty"`
+	Active  bool
+	Score   float64 `url:"score"`
+}
+
+func TestEncodeQuery(t *testing.T) {
+	until := time.Unix(1700000000, 0)
+	filter := &queryFilter{
+		queryPage: queryPage{Size: 20},
+		Keyword:   "go tools",
+		Tags:      []string{"a", "b"},
+		IDs:       []int{1, 2, 3},
+		Since:     time.Date(2022, 10, 17, 0, 0, 0, 0, time.UTC),
+		Until:     &until,
+		Extra:     map[string]string{"k": "v"},
+		Secret:    "secret",
+		Active:    true,
+		Score:     1.5,
+	}
+	filter.Owner.Name = "shen"
+
+	values, err := EncodeQuery(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "Active=true&extra.k=v&ids=1%2C2%2C3&owner.Name=shen&q=go+tools&score=1.5&since=2022-10-17&size=20&tag=a&tag=b&until=1700000000"
+	if values.Encode() != expect {
+		t.Fatal(values.Encode())
+	}
+
+	if _, err = EncodeQuery(1); err == nil {
+		t.Fatal("expect error for int")
+	}
+}
+
+func TestBuildURL(t *testing.T) {
+	cases := []struct {
+		base   string
+		path   string
+		params map[string]string
+		query  url.Values
+		expect string
+	}{
+		{"https://api.example.com/v1", "/users/{id}", map[string]string{"id": "a b/c"}, nil, "https://api.example.com/v1/users/a%20b%2Fc"},
+		{"https://api.example.com/v1/", "users", nil, url.Values{"page": {"2"}}, "https://api.example.com/v1/users?page=2"},
+		{"https://api.example.com/{org}/repos?key=k", "", map[string]string{"org": "go"}, url.Values{"key": {"k2"}, "a": {"1"}}, "https://api.example.com/go/repos?key=k&a=1&key=k2"},
+		// base中的query保持原有的顺序和转义
+		{"https://s3.example.com/b/o?X-Sig=a%2Fb&A=1#frag", "", nil, url.Values{"c": {"x y"}}, "https://s3.example.com/b/o?X-Sig=a%2Fb&A=1&c=x+y#frag"},
+	}
+	for _, c := range cases {
+		result, err := BuildURL(c.base, c.path, c.params, c.query)
+		if err != nil || result != c.expect {
+			t.Error(c.base, c.path, result, err)
+		}
+	}
+
+	if _, err := BuildURL("https://api.example.com", "/users/{id}", nil, nil); err == nil {
+		t.Fatal("expect missing path param error")
+	}
+}
+
+func TestGetWithQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":%q}`, r.URL.RequestURI())
+	}))
+	defer server.Close()
+
+	user := new(User)
+	Get(server.URL+"/users/{id}?sort=asc", url.Values{"page": {"1"}}, user,
+		NetPathParams(map[string]string{"id": "7"}), NetQuery(queryPage{Size: 10}), NetLogLevelOption(NetLogNone))
+	if user.Title != "/users/7?sort=asc&page=1&size=10" {
+		t.Fatal(user.Title)
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	values := url.Values{"b": {"2"}, "a": {"1 2"}}
+	for urlStr, expect := range map[string]string{
+		"https://example.com/path":  "https://example.com/path?a=1+2&b=2",
+		"https://example.com/path?": "https://example.com/path?a=1+2&b=2",
+		// 预签名url已有的query保持原样
+		"https://s3.example.com/key?X-Amz-Signature=abc%2Fdef&X-Amz-Date=20240101T000000Z": "https://s3.example.com/key?X-Amz-Signature=abc%2Fdef&X-Amz-Date=20240101T000000Z&a=1+2&b=2",
+		"https://example.com/path?z=1&#top":                                                "https://example.com/path?z=1&a=1+2&b=2#top",
+	} {
+		if result := appendQuery(urlStr, values); result != expect {
+			t.Errorf("\n%s\n%s", result, expect)
+		}
+	}
+	if result := appendQuery("https://example.com/?z=%20", nil); result != "https://example.com/?z=%20" {
+		t.Error(result)
+	}
+}
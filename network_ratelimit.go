@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRateLimited 限流时使用NetRateLimitFailFast, 没有可用令牌会直接返回该错误
+var ErrRateLimited = errors.New("tools: rate limited")
+
+// Limiter 请求限流器, 实现需要并发安全
+type Limiter interface {
+	// Wait 阻塞直到可以发出请求, ctx取消时返回ctx.Err()
+	Wait(ctx context.Context, request *http.Request) error
+	// Allow 有可用令牌时消耗一个并返回true, 不阻塞
+	Allow(request *http.Request) bool
+}
+
+// LimiterStats 限流统计
+type LimiterStats struct {
+	Allowed   int64         // 放行的请求数(包括等待后放行的)
+	Waited    int64         // 需要等待的请求数
+	Rejected  int64         // fail fast或ctx取消而没有放行的请求数
+	Waiting   int64         // 当前正在等待的请求数
+	TotalWait time.Duration // 累计等待时长
+	MaxWait   time.Duration // 最长一次等待时长
+}
+
+// AvgWait 平均等待时长(只统计需要等待的请求)
+func (s LimiterStats) AvgWait() time.Duration {
+	if s.Waited == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Waited)
+}
+
+func (s *LimiterStats) add(other LimiterStats) {
+	s.Allowed += other.Allowed
+	s.Waited += other.Waited
+	s.Rejected += other.Rejected
+	s.Waiting += other.Waiting
+	s.TotalWait += other.TotalWait
+	if other.MaxWait > s.MaxWait {
+		s.MaxWait = other.MaxWait
+	}
+}
+
+// TokenBucket 令牌桶, 每秒补充rate个令牌, 最多积攒burst个
+type TokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	stats  LimiterStats
+}
+
+// NewTokenBucket 创建令牌桶, 初始是满的
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// refill 需要持有锁
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// Allow 有可用令牌时消耗一个并返回true
+func (b *TokenBucket) Allow(*http.Request) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		b.stats.Rejected++
+		return false
+	}
+	b.tokens--
+	b.stats.Allowed++
+	return true
+}
+
+// Wait 预定一个令牌并等待到可用, ctx取消时归还令牌
+func (b *TokenBucket) Wait(ctx context.Context, _ *http.Request) error {
+	b.lock.Lock()
+	b.refill(time.Now())
+	b.tokens--
+	if b.tokens >= 0 {
+		b.stats.Allowed++
+		b.lock.Unlock()
+		return nil
+	}
+	if b.rate <= 0 {
+		b.tokens++
+		b.stats.Rejected++
+		b.lock.Unlock()
+		return ErrRateLimited
+	}
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.stats.Waiting++
+	b.lock.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		b.lock.Lock()
+		b.stats.Waiting--
+		b.stats.Allowed++
+		b.stats.Waited++
+		b.stats.TotalWait += wait
+		if wait > b.stats.MaxWait {
+			b.stats.MaxWait = wait
+		}
+		b.lock.Unlock()
+		return nil
+	case <-ctx.Done():
+		b.lock.Lock()
+		b.stats.Waiting--
+		b.stats.Rejected++
+		b.tokens++
+		b.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Stats 限流统计
+func (b *TokenBucket) Stats() LimiterStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.stats
+}
+
+// HostLimiter 按请求的host分别限流, 未单独设置的host使用默认的rate和burst
+type HostLimiter struct {
+	lock    sync.Mutex
+	rate    float64
+	burst   int
+	limits  map[string][2]float64
+	buckets map[string]*TokenBucket
+}
+
+// NewHostLimiter 每个host默认每秒rate个请求, 最多积攒burst个
+func NewHostLimiter(rate float64, burst int) *HostLimiter {
+	return &HostLimiter{
+		rate:    rate,
+		burst:   burst,
+		limits:  make(map[string][2]float64),
+		buckets: make(map[string]*TokenBucket),
+	}
+}
+
+// SetHostLimit 单独设置某个host的限流, host需要与URL中的一致(包括端口)
+func (l *HostLimiter) SetHostLimit(host string, rate float64, burst int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.limits[host] = [2]float64{rate, float64(burst)}
+	delete(l.buckets, host)
+}
+
+func (l *HostLimiter) bucket(host string) *TokenBucket {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		rate, burst := l.rate, l.burst
+		if limit, ok := l.limits[host]; ok {
+			rate, burst = limit[0], int(limit[1])
+		}
+		bucket = NewTokenBucket(rate, burst)
+		l.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// Wait 阻塞直到request所在host可以发出请求
+func (l *HostLimiter) Wait(ctx context.Context, request *http.Request) error {
+	return l.bucket(request.URL.Host).Wait(ctx, request)
+}
+
+// Allow request所在host有可用令牌时返回true
+func (l *HostLimiter) Allow(request *http.Request) bool {
+	return l.bucket(request.URL.Host).Allow(request)
+}
+
+// HostStats 某个host的限流统计
+func (l *HostLimiter) HostStats(host string) LimiterStats {
+	l.lock.Lock()
+	bucket, ok := l.buckets[host]
+	l.lock.Unlock()
+	if !ok {
+		return LimiterStats{}
+	}
+	return bucket.Stats()
+}
+
+// Stats 所有host的限流统计之和
+func (l *HostLimiter) Stats() LimiterStats {
+	l.lock.Lock()
+	buckets := make([]*TokenBucket, 0, len(l.buckets))
+	for _, bucket := range l.buckets {
+		buckets = append(buckets, bucket)
+	}
+	l.lock.Unlock()
+
+	var stats LimiterStats
+	for _, bucket := range buckets {
+		stats.add(bucket.Stats())
+	}
+	return stats
+}
+
+type limiterHolder struct {
+	limiter Limiter
+}
+
+var defaultLimiter atomic.Value // limiterHolder
+
+// SetRateLimiter 设置全局限流器, 传nil关闭, 单次请求可以通过NetRateLimiter覆盖, 并发安全
+func SetRateLimiter(limiter Limiter) {
+	defaultLimiter.Store(limiterHolder{limiter: limiter})
+}
+
+// GetRateLimiter SetRateLimiter设置的限流器, 没有设置时为nil
+func GetRateLimiter() Limiter {
+	holder, _ := defaultLimiter.Load().(limiterHolder)
+	return holder.limiter
+}
+
+// limitTransport 每次真正发出请求前限流, 命中缓存的请求不受影响
+type limitTransport struct {
+	next     http.RoundTripper
+	limiter  Limiter
+	failFast bool
+}
+
+func (t *limitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.failFast {
+		if !t.limiter.Allow(request) {
+			return nil, ErrRateLimited
+		}
+	} else if err := t.limiter.Wait(request.Context(), request); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(request)
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(20, 2)
+	if !bucket.Allow(nil) || !bucket.Allow(nil) || bucket.Allow(nil) {
+		t.Fatal("burst should be 2")
+	}
+
+	start := time.Now()
+	if err := bucket.Wait(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if cost := time.Since(start); cost < 30*time.Millisecond {
+		t.Fatal("should wait for refill", cost)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := bucket.Wait(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	stats := bucket.Stats()
+	if stats.Allowed != 3 || stats.Waited != 1 || stats.Rejected != 2 || stats.Waiting != 0 || stats.MaxWait <= 0 {
+		t.Fatal(stats)
+	}
+}
+
+func TestNetRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	limiter := NewHostLimiter(1000, 10)
+	limiter.SetHostLimit(host, 0.001, 1)
+	options := []NetOptionFunc{NetRateLimiter(limiter), NetLogLevelOption(NetLogNone)}
+
+	if err := Get(server.URL, nil, nil, options...); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get(server.URL, nil, nil, append(options, NetRateLimitFailFast())...); !errors.Is(err, ErrRateLimited) {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Get(server.URL, url.Values{"a": {"1"}}, nil, append(options, NetContext(ctx))...); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+
+	stats := limiter.HostStats(host)
+	if stats.Allowed != 1 || stats.Rejected != 2 || limiter.Stats() != stats {
+		t.Fatal(stats)
+	}
+}
+
+func TestSetRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	defer SetRateLimiter(nil)
+	options := []NetOptionFunc{NetRateLimitFailFast(), NetLogLevelOption(NetLogNone)}
+
+	limiter := NewTokenBucket(0.001, 1)
+	SetRateLimiter(limiter)
+	if GetRateLimiter() != limiter {
+		t.Fatal("expect limiter")
+	}
+	if err := Get(server.URL, nil, nil, options...); err != nil {
+		t.Fatal(err)
+	}
+	if err := Get(server.URL, nil, nil, options...); !errors.Is(err, ErrRateLimited) {
+		t.Fatal(err)
+	}
+
+	// 请求过程中切换全局限流器是并发安全的
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetRateLimiter(NewTokenBucket(1000, 10))
+		}()
+		go func() {
+			defer wg.Done()
+			Get(server.URL, nil, nil, options...)
+		}()
+	}
+	wg.Wait()
+	SetRateLimiter(nil)
+	if GetRateLimiter() != nil {
+		t.Fatal("expect nil")
+	}
+}
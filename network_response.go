@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Response 响应的元信息, 由GetResponse等与反序列化的obj一起返回
+type Response struct {
+	StatusCode int // 没有收到响应时为0
+	Header     http.Header
+	Body       []byte        // 原始响应体(已解压), obj为*http.Response或NetStream流式解析时为nil
+	Duration   time.Duration // 从发出请求到读取完响应体的耗时
+	Retries    int           // 同一个请求实际发出的次数减1, 如收到401刷新token后的重试, 重定向不算
+	URL        string        // 重定向后最终的url, 没有收到响应时为请求的url
+}
+
+// GetResponse 同Get, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func GetResponse(urlStr string, values url.Values, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := Get(urlStr, values, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// DeleteResponse 同Delete, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func DeleteResponse(urlStr string, values url.Values, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := Delete(urlStr, values, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// PostResponse 同Post, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func PostResponse(url string, data interface{}, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := Post(url, data, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// PutResponse 同Put, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func PutResponse(url string, data interface{}, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := Put(url, data, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// PatchResponse 同Patch, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func PatchResponse(url string, data interface{}, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := Patch(url, data, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// FormDataPostResponse 同FormDataPost, 同时返回响应的元信息, 出错时返回已经获取到的部分
+func FormDataPostResponse(url string, data map[string]string, obj interface{}, options ...NetOptionFunc) (*Response, error) {
+	response := new(Response)
+	err := FormDataPost(url, data, obj, withResponse(response, options)...)
+	return response, err
+}
+
+// withResponse 保留调用方的日志级别, 最后设置response
+func withResponse(response *Response, options []NetOptionFunc) []NetOptionFunc {
+	merged := make([]NetOptionFunc, 0, len(options)+2)
+	merged = append(merged, NetLogLevelOption(configWithOptions(options...).NetLogLevel))
+	merged = append(merged, options...)
+	return append(merged, func(o *netOptions) {
+		o.response = response
+		// 多了一层XxxResponse方法的调用
+		o.LogCallerSkip++
+		o.LogLineSkip++
+	})
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetResponse(t *testing.T) {
+	var unauthorized int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/auth", http.StatusFound)
+			return
+		case "/auth":
+			if atomic.AddInt32(&unauthorized, 1) == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Header().Set("X-Request-Id", "abc")
+		fmt.Fprintf(w, `{"id":1,"title":"%s %s"}`, r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+	logNone := NetLogLevelOption(NetLogNone)
+
+	user := new(User)
+	response, err := GetResponse(server.URL+"/users/1", nil, user)
+	if err != nil || user.ID != 1 || response.StatusCode != http.StatusOK || response.Header.Get("X-Request-Id") != "abc" ||
+		string(response.Body) != `{"id":1,"title":"GET /users/1"}` || response.Duration <= 0 || response.Retries != 0 || response.URL != server.URL+"/users/1" {
+		t.Fatal(err, user, response)
+	}
+
+	// 重定向不算重试, 401刷新token后的重试算
+	response, err = GetResponse(server.URL+"/old", nil, nil, logNone, NetTokenSource(new(invalidatingTokenSource)))
+	if err != nil || response.StatusCode != http.StatusOK || response.Retries != 1 || response.URL != server.URL+"/auth" {
+		t.Error(err, response)
+	}
+
+	// UnmarshalPath不影响原始响应体
+	var id int
+	response, err = PostResponse(server.URL+"/missing", &User{ID: 2}, &id, logNone, UnmarshalPath([]interface{}{"id"}))
+	if err != nil || id != 1 || response.StatusCode != http.StatusNotFound || string(response.Body) != `{"id":1,"title":"POST /missing"}` {
+		t.Error(err, id, response)
+	}
+
+	for _, call := range []func() (*Response, error){
+		func() (*Response, error) { return PutResponse(server.URL, nil, nil, logNone) },
+		func() (*Response, error) { return PatchResponse(server.URL, nil, nil, logNone) },
+		func() (*Response, error) { return DeleteResponse(server.URL, nil, nil, logNone) },
+	} {
+		if response, err = call(); err != nil || response.StatusCode != http.StatusOK || len(response.Body) == 0 {
+			t.Error(err, response)
+		}
+	}
+
+	// obj为*http.Response时不读取响应体
+	raw := new(http.Response)
+	if response, err = GetResponse(server.URL, nil, raw, logNone); err != nil || response.Body != nil || response.StatusCode != raw.StatusCode {
+		t.Error(err, response)
+	}
+	raw.Body.Close()
+
+	response, err = GetResponse("http://127.0.0.1:1/refused", nil, nil, logNone)
+	if err == nil || response.StatusCode != 0 || response.URL != "http://127.0.0.1:1/refused" {
+		t.Error(err, response)
+	}
+	response, err = FormDataPostResponse("http://127.0.0.1:1/refused", map[string]string{"title": "hello"}, nil, logNone)
+	if err == nil || response.StatusCode != 0 {
+		t.Error(err, response)
+	}
+}
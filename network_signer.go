@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer 请求签名, body为完整的请求体(没有请求体时为nil), 在请求真正发出前调用, 重试时会重新签名
+type Signer interface {
+	Sign(request *http.Request, body []byte) error
+}
+
+// HMACSigner HMAC-SHA256签名, 签名内容为 "METHOD\nPATH?QUERY\nTIMESTAMP\nhex(sha256(body))"
+// 会设置TimestampHeader(unix秒), SignatureHeader(hex编码的签名), KeyID不为空时设置"X-Key-Id"
+type HMACSigner struct {
+	Key             []byte
+	KeyID           string
+	SignatureHeader string           // default: "X-Signature"
+	TimestampHeader string           // default: "X-Timestamp"
+	Now             func() time.Time // default: time.Now
+}
+
+// StringToSign 待签名的字符串
+func (s *HMACSigner) StringToSign(request *http.Request, body []byte, timestamp string) string {
+	return strings.Join([]string{request.Method, request.URL.RequestURI(), timestamp, sha256Hex(body)}, "\n")
+}
+
+// Sign 签名
+func (s *HMACSigner) Sign(request *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	signatureHeader, timestampHeader := s.SignatureHeader, s.TimestampHeader
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature"
+	}
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	signature := hmacSHA256(s.Key, s.StringToSign(request, body, timestamp))
+	request.Header.Set(timestampHeader, timestamp)
+	request.Header.Set(signatureHeader, hex.EncodeToString(signature))
+	if s.KeyID != "" {
+		request.Header.Set("X-Key-Id", s.KeyID)
+	}
+	return nil
+}
+
+// SigV4Signer AWS Signature Version 4签名, 签名的请求头为host, content-type和所有x-amz-*
+// path按未编码的URL.Path进行编码, 即不支持path中本身包含%的情况
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+	Now             func() time.Time // default: time.Now
+}
+
+// Sign 签名, 设置X-Amz-Date, Authorization, 有SessionToken时设置X-Amz-Security-Token, service为s3时设置X-Amz-Content-Sha256
+func (s *SigV4Signer) Sign(request *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	request.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.Service == "s3" {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalRequest, signedHeaders := s.CanonicalRequest(request, payloadHash)
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// CanonicalRequest SigV4规范请求, 返回规范请求和签名的请求头列表
+func (s *SigV4Signer) CanonicalRequest(request *http.Request, payloadHash string) (canonicalRequest string, signedHeaders string) {
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			trimmed := make([]string, len(values))
+			for i, value := range values {
+				trimmed[i] = strings.Join(strings.Fields(value), " ")
+			}
+			headers[name] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders = strings.Join(names, ";")
+
+	path := request.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+
+	query := request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+
+	canonicalRequest = strings.Join([]string{
+		request.Method,
+		strings.Join(segments, "/"),
+		strings.Join(pairs, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	return
+}
+
+// awsEscape 除了A-Z a-z 0-9 - _ . ~ 之外都进行编码
+func awsEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(url.QueryEscape(s), "+", "%20"), "%7E", "~")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signTransport 读取完整请求体后签名
+type signTransport struct {
+	next   http.RoundTripper
+	signer Signer
+}
+
+func (t *signTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	var body []byte
+	var err error
+	if request.Body != nil && request.Body != http.NoBody {
+		reader := request.Body
+		if request.GetBody != nil {
+			if reader, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+			request.Body.Close()
+		}
+		body, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if err = t.signer.Sign(request, body); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(request)
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// https://www.rfc-editor.org/rfc/rfc4231#section-4.3
+func TestHMACSHA256Vector(t *testing.T) {
+	sum := hex.EncodeToString(hmacSHA256([]byte("Jefe"), "what do ya want for nothing?"))
+	if sum != "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Fatal(sum)
+	}
+}
+
+// https://docs.aws.amazon.com/general/latest/gr/signature-v4-test-suite.html
+func TestSigV4Vectors(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name        string
+		service     string
+		url         string
+		contentType string
+		auth        string
+	}{
+		{"get-vanilla", "service", "https://example.amazonaws.com/", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "service", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"iam-list-users", "iam", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", "application/x-www-form-urlencoded; charset=utf-8",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"},
+	}
+
+	for _, c := range cases {
+		signer := &SigV4Signer{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			Region:          "us-east-1",
+			Service:         c.service,
+			Now:             now,
+		}
+		request, _ := http.NewRequest(http.MethodGet, c.url, nil)
+		if c.contentType != "" {
+			request.Header.Set("Content-Type", c.contentType)
+		}
+		signer.Sign(request, nil)
+		if auth := request.Header.Get("Authorization"); auth != c.auth {
+			t.Error(c.name, auth)
+		}
+	}
+}
+
+func TestNetSigner(t *testing.T) {
+	signer := &HMACSigner{Key: []byte("key"), KeyID: "k1", Now: func() time.Time { return time.Unix(1700000000, 0) }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		r.Body.Read(body)
+		expect := hex.EncodeToString(hmacSHA256([]byte("key"), signer.StringToSign(r, body, r.Header.Get("X-Timestamp"))))
+		if r.Header.Get("X-Signature") != expect || r.Header.Get("X-Timestamp") != "1700000000" || r.Header.Get("X-Key-Id") != "k1" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	res := new(http.Response)
+	if err := Post(server.URL+"/path?a=1", map[string]int{"a": 1}, res, NetSigner(signer), NetLogLevelOption(NetLogNone)); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatal(err, res.StatusCode)
+	}
+	if err := Get(server.URL, nil, res, NetSigner(signer), NetLogLevelOption(NetLogNone)); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatal(err, res.StatusCode)
+	}
+}
@@ -9,7 +9,14 @@
 package tools
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
 type User struct {
@@ -19,16 +26,58 @@ type User struct {
 	Body   string `json:"body" bson:"body"`
 }
 
+// newPostsServer 模拟jsonplaceholder的/posts接口, 测试不依赖外网
+func newPostsServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		post := new(User)
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/posts/"):
+			id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/posts/"))
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			*post = User{ID: id, UserID: 1, Title: "title " + strconv.Itoa(id), Body: "body"}
+		case r.Method == http.MethodPost && r.URL.Path == "/posts":
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				post.UserID, _ = strconv.Atoi(r.FormValue("userId"))
+				post.Title, post.Body = r.FormValue("title"), r.FormValue("body")
+			} else if err := jsoniter.NewDecoder(r.Body).Decode(post); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			post.ID = 101
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		jsoniter.NewEncoder(w).Encode(post)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestHttpGet(t *testing.T) {
+	server := newPostsServer(t)
 	user := new(User)
-	Get("https://jsonplaceholder.typicode.com/posts/1", nil, nil, NetLogLevelOption(NetLogNone))
-	Get("https://jsonplaceholder.typicode.com/posts/2", nil, nil, NetLogLevelOption(NetLogURL), LogCallerSkipOption(-2), LogLineSkipOption(-2))
-	Get("https://jsonplaceholder.typicode.com/posts/3", nil, nil, NetLogLevelOption(NetLogURL|NetLogParams))
-	Get("https://jsonplaceholder.typicode.com/posts/4", nil, nil, NetLogLevelOption(NetLogAll))
-	Get("https://jsonplaceholder.typicode.com/posts/5", nil, user)
+	Get(server.URL+"/posts/1", nil, nil, NetLogLevelOption(NetLogNone))
+	Get(server.URL+"/posts/2", nil, nil, NetLogLevelOption(NetLogURL), LogCallerSkipOption(-2), LogLineSkipOption(-2))
+	Get(server.URL+"/posts/3", nil, nil, NetLogLevelOption(NetLogURL|NetLogParams))
+	Get(server.URL+"/posts/4", nil, nil, NetLogLevelOption(NetLogAll))
+	if err := Get(server.URL+"/posts/5", nil, user); err != nil || user.ID != 5 || user.Title != "title 5" {
+		t.Error(err, user)
+	}
 }
 
 func TestHttpPost(t *testing.T) {
+	server := newPostsServer(t)
 
 	user := new(User)
 	user.UserID = 1
@@ -37,17 +86,42 @@ func TestHttpPost(t *testing.T) {
 	user.Body = "body"
 
 	newUser := new(User)
+	if err := Post(server.URL+"/posts", user, newUser); err != nil || *newUser != *user {
+		t.Error(err, newUser)
+	}
+}
 
-	// params := map[string]interface{}{
-	// 	"userId": "1",
-	// 	"id":     "101",
-	// 	"title":  "title test",
-	// 	"body":   "body test",
-	// }
-	Post("https://jsonplaceholder.typicode.com/posts", user, newUser)
+func TestHttpPostCodec(t *testing.T) {
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- r.Header.Get("Content-Type") + "\n" + string(body)
+	}))
+	defer server.Close()
+	logNone := NetLogLevelOption(NetLogNone)
+
+	// 通过NetHeader设置的Content-Type决定请求体的序列化方式
+	header := http.Header{"Content-Type": {"application/x-yaml"}}
+	if err := Post(server.URL, map[string]string{"title": "hello"}, nil, logNone, NetHeader(header)); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-bodies; body != "application/x-yaml\ntitle: hello\n" {
+		t.Error(body)
+	}
+
+	// 序列化失败时返回错误, 不发出请求
+	if err := Post(server.URL, make(chan int), nil, logNone); err == nil {
+		t.Error("expect marshal error")
+	}
+	select {
+	case body := <-bodies:
+		t.Error("unexpected request", body)
+	default:
+	}
 }
 
 func TestHttpFormDataPost(t *testing.T) {
+	server := newPostsServer(t)
 	user := new(User)
 	params := map[string]string{
 		"userId": "1",
@@ -55,5 +129,31 @@ func TestHttpFormDataPost(t *testing.T) {
 		"title":  "title test",
 		"body":   "body test",
 	}
-	FormDataPost("https://jsonplaceholder.typicode.com/posts", params, user)
+	FormDataPost(server.URL+"/posts", params, user)
+	if user.ID != 101 || user.UserID != 1 || user.Title != "title test" || user.Body != "body test" {
+		t.Error(user)
+	}
+}
+
+type transportFunc func(request *http.Request) (*http.Response, error)
+
+func (f transportFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestSetTransport(t *testing.T) {
+	SetTransport(transportFunc(func(request *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"id":7}`)), Request: request}, nil
+	}))
+	defer SetTransport(nil)
+
+	user := new(User)
+	if err := Get("https://example.invalid/users/7", nil, user, NetLogLevelOption(NetLogNone)); err != nil || user.ID != 7 {
+		t.Error(err, user)
+	}
+	// Session的client没有设置Transport, 同样生效
+	user = new(User)
+	if err := NewSession(NetLogLevelOption(NetLogNone)).Get("https://example.invalid/users/7", nil, user); err != nil || user.ID != 7 {
+		t.Error(err, user)
+	}
 }
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":"%s"}`, r.Proto)
+	}))
+	defer server.Close()
+	user := new(User)
+
+	if err := Get(server.URL, nil, user, NetLogLevelOption(NetLogNone)); err == nil {
+		t.Fatal("self-signed certificate should fail")
+	}
+
+	client, err := NewHTTPClient(TransportInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Get(server.URL, nil, user, NetHTTPClient(client), NetLogLevelOption(NetLogNone)); err != nil {
+		t.Fatal(err)
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err = NewHTTPClient(TransportRootCAPEM(caPEM), TransportHTTP2(false), TransportConnPool(10, 5, 5), TransportKeepAlive(-1, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Get(server.URL, nil, user, NetHTTPClient(client), NetLogLevelOption(NetLogNone)); err != nil || user.Title != "HTTP/1.1" {
+		t.Fatal(err, user)
+	}
+
+	if _, err = NewHTTPClient(TransportRootCAPEM([]byte("invalid"))); err == nil {
+		t.Fatal("invalid CA should fail")
+	}
+}
+
+func TestTransportMutualTLS(t *testing.T) {
+	clientCert, clientCertPool := testCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":"%s"}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCertPool}
+	server.StartTLS()
+	defer server.Close()
+
+	user := new(User)
+	client, _ := NewHTTPClient(TransportInsecureSkipVerify())
+	if err := Get(server.URL, nil, user, NetHTTPClient(client), NetLogLevelOption(NetLogNone)); err == nil {
+		t.Fatal("request without client certificate should fail")
+	}
+
+	client, _ = NewHTTPClient(TransportInsecureSkipVerify(), TransportClientCert(clientCert))
+	if err := Get(server.URL, nil, user, NetHTTPClient(client), NetLogLevelOption(NetLogNone)); err != nil || user.Title != "go-tools-client" {
+		t.Fatal(err, user)
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":"proxy %s"}`, r.URL.Host)
+	}))
+	defer proxy.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"title":"direct"}`))
+	}))
+	defer target.Close()
+
+	client, err := NewHTTPClient(
+		TransportProxyForHost("127.0.0.1", "direct"),
+		TransportProxyForHost("*.example.com", proxy.URL),
+		TransportProxy("socks5://127.0.0.1:1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSession(NetHTTPClient(client), NetLogLevelOption(NetLogNone))
+	user := new(User)
+	if err = session.Get("http://api.example.com/users", nil, user); err != nil || user.Title != "proxy api.example.com" {
+		t.Fatal(err, user)
+	}
+	if err = session.Get(target.URL, nil, user); err != nil || user.Title != "direct" {
+		t.Fatal(err, user)
+	}
+	// 其他host走socks5代理, 代理不可用
+	if err = session.Get("http://other.test/", nil, user); err == nil {
+		t.Fatal("expect socks5 proxy error")
+	}
+
+	if _, err = NewHTTPClient(TransportProxy("ftp://127.0.0.1")); err == nil {
+		t.Fatal("unsupported proxy scheme should fail")
+	}
+}
+
+// testCertificate 生成自签名的客户端证书
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-tools-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
@@ -0,0 +1,505 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RedactOptionFunc SetRedaction配置
+type RedactOptionFunc func(r *redactor)
+
+type redactPattern struct {
+	regexp  *regexp.Regexp
+	replace func(match string) string
+}
+
+type redactor struct {
+	mask      string
+	headers   map[string]bool // 规范化后的请求头名称
+	jsonPaths [][]string
+	jsonKeys  map[string]bool // 小写
+	patterns  []redactPattern
+}
+
+// defaultRedactHeaders 默认隐藏的请求头/响应头
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var redactorLock sync.RWMutex
+var currentRedactor = newRedactor()
+
+var headerType = reflect.TypeOf(http.Header(nil))
+
+// maxRedactDepth 格式化struct时的最大递归深度, 避免循环引用
+const maxRedactDepth = 16
+
+func newRedactor(options ...RedactOptionFunc) *redactor {
+	r := &redactor{mask: "***", headers: make(map[string]bool), jsonKeys: make(map[string]bool)}
+	RedactHeaders(defaultRedactHeaders...)(r)
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// SetRedaction 设置日志脱敏规则, 作用于Log*/Info*等方法和网络请求的日志, 每次调用会替换之前的规则.
+// Authorization/Proxy-Authorization/Cookie/Set-Cookie请求头始终会被隐藏,
+// struct字段的`log:"-"`(不打印)和`log:"mask"`(打印为掩码)不需要设置即生效
+func SetRedaction(options ...RedactOptionFunc) {
+	r := newRedactor(options...)
+	redactorLock.Lock()
+	defer redactorLock.Unlock()
+	currentRedactor = r
+}
+
+func getRedactor() *redactor {
+	redactorLock.RLock()
+	defer redactorLock.RUnlock()
+	return currentRedactor
+}
+
+// RedactMask 替换敏感数据的掩码, default: "***"
+func RedactMask(mask string) RedactOptionFunc {
+	return func(r *redactor) {
+		r.mask = mask
+	}
+}
+
+// RedactHeaders 需要隐藏的请求头/响应头, 不区分大小写
+func RedactHeaders(names ...string) RedactOptionFunc {
+	return func(r *redactor) {
+		for _, name := range names {
+			r.headers[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// RedactJSONPaths 需要隐藏的json路径, 以"."分隔, "*"匹配任意key或数组下标, 不区分大小写, eg: "user.password", "cards.*.number".
+// 同时作用于struct字段(使用json tag的名称)和map
+func RedactJSONPaths(paths ...string) RedactOptionFunc {
+	return func(r *redactor) {
+		for _, path := range paths {
+			r.jsonPaths = append(r.jsonPaths, strings.Split(strings.ToLower(path), "."))
+		}
+	}
+}
+
+// RedactJSONKeys 需要隐藏的key, 匹配任意层级, 不区分大小写, eg: "password", "token".
+// 同时作用于struct字段(json tag的名称或字段名)和map
+func RedactJSONKeys(keys ...string) RedactOptionFunc {
+	return func(r *redactor) {
+		for _, key := range keys {
+			r.jsonKeys[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// RedactPattern 将日志中匹配的内容替换为replacement, replacement支持$1等分组引用, 为空时使用掩码
+func RedactPattern(pattern *regexp.Regexp, replacement string) RedactOptionFunc {
+	return func(r *redactor) {
+		r.patterns = append(r.patterns, redactPattern{regexp: pattern, replace: func(match string) string {
+			if replacement == "" {
+				return r.mask
+			}
+			return pattern.ReplaceAllString(match, replacement)
+		}})
+	}
+}
+
+var emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// RedactEmails 隐藏邮箱的用户名部分, eg: "***@example.com"
+func RedactEmails() RedactOptionFunc {
+	return func(r *redactor) {
+		r.patterns = append(r.patterns, redactPattern{regexp: emailRegexp, replace: func(match string) string {
+			return r.mask + match[strings.LastIndex(match, "@"):]
+		}})
+	}
+}
+
+var cardNumberRegexp = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// RedactCardNumbers 隐藏13~19位的银行卡号(可以包含空格或-), 只保留后4位, eg: "************1234"
+func RedactCardNumbers() RedactOptionFunc {
+	return func(r *redactor) {
+		r.patterns = append(r.patterns, redactPattern{regexp: cardNumberRegexp, replace: func(match string) string {
+			digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+			return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+		}})
+	}
+}
+
+// redactString 应用正则规则
+func (r *redactor) redactString(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.regexp.ReplaceAllStringFunc(s, pattern.replace)
+	}
+	return s
+}
+
+// redactHeader 返回隐藏了敏感请求头的副本
+func (r *redactor) redactHeader(header http.Header) (http.Header, bool) {
+	redacted := header
+	changed := false
+	for name, values := range header {
+		if !r.headers[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		if !changed {
+			redacted = header.Clone()
+			changed = true
+		}
+		masked := make([]string, len(values))
+		for i := range masked {
+			masked[i] = r.mask
+		}
+		redacted[name] = masked
+	}
+	return redacted, changed
+}
+
+// matchPath path中的key为小写, keyed为false时(数组元素)只按RedactJSONPaths匹配
+func (r *redactor) matchPath(path []string, keyed bool) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if keyed && r.jsonKeys[path[len(path)-1]] {
+		return true
+	}
+	for _, jsonPath := range r.jsonPaths {
+		if len(jsonPath) != len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range jsonPath {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactJSON 隐藏json中匹配的字段, 保持原有的key顺序, 有修改时会输出为紧凑格式
+func (r *redactor) redactJSON(data []byte) ([]byte, bool) {
+	if len(r.jsonPaths) == 0 && len(r.jsonKeys) == 0 {
+		return data, false
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || !json.Valid(trimmed) {
+		return data, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	buf := new(bytes.Buffer)
+	changed := false
+	if err := r.writeJSON(decoder, buf, nil, &changed); err != nil || !changed {
+		return data, false
+	}
+	return buf.Bytes(), true
+}
+
+func (r *redactor) writeJSON(decoder *json.Decoder, buf *bytes.Buffer, path []string, changed *bool) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		object := value == '{'
+		buf.WriteRune(rune(value))
+		for i := 0; decoder.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key := strconv.Itoa(i)
+			if object {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key = keyToken.(string)
+				writeJSONString(buf, key)
+				buf.WriteByte(':')
+			}
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			if r.matchPath(childPath, object) {
+				if err = skipJSONValue(decoder); err != nil {
+					return err
+				}
+				writeJSONString(buf, r.mask)
+				*changed = true
+				continue
+			}
+			if err = r.writeJSON(decoder, buf, childPath, changed); err != nil {
+				return err
+			}
+		}
+		// 结束符
+		if _, err = decoder.Token(); err != nil {
+			return err
+		}
+		if object {
+			buf.WriteByte('}')
+		} else {
+			buf.WriteByte(']')
+		}
+	case string:
+		writeJSONString(buf, value)
+	case json.Number:
+		buf.WriteString(value.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	// Encode会在末尾加换行
+	buf.Truncate(buf.Len() - 1)
+}
+
+// redactArg 脱敏单个日志参数, 没有需要脱敏的内容时返回原值
+func (r *redactor) redactArg(arg interface{}) interface{} {
+	switch value := arg.(type) {
+	case nil, error, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, LogOptionFunc:
+		return arg
+	case string:
+		if redacted, changed := r.redactJSON([]byte(value)); changed {
+			return string(redacted)
+		}
+		return arg
+	case []byte:
+		if redacted, changed := r.redactJSON(value); changed {
+			return redacted
+		}
+		return arg
+	case http.Header:
+		if redacted, changed := r.redactHeader(value); changed {
+			return redacted
+		}
+		return arg
+	case *http.Response:
+		// 保持原有的打印格式, 只替换Header
+		if value == nil {
+			return arg
+		}
+		if redacted, changed := r.redactHeader(value.Header); changed {
+			copied := *value
+			copied.Header = redacted
+			return &copied
+		}
+		return arg
+	case *http.Request:
+		if value == nil {
+			return arg
+		}
+		if redacted, changed := r.redactHeader(value.Header); changed {
+			copied := *value
+			copied.Header = redacted
+			return &copied
+		}
+		return arg
+	}
+
+	v := reflect.ValueOf(arg)
+	if len(r.jsonPaths) == 0 && len(r.jsonKeys) == 0 && !hasLogTag(v.Type()) {
+		return arg
+	}
+	changed := false
+	formatted := r.formatValue(v, nil, 0, &changed)
+	if !changed {
+		return arg
+	}
+	return formatted
+}
+
+// formatValue 按%#v的格式输出, 同时处理log tag和json路径
+func (r *redactor) formatValue(value reflect.Value, path []string, depth int, changed *bool) string {
+	if !value.IsValid() || depth > maxRedactDepth {
+		return fmt.Sprintf("%#v", value)
+	}
+	valueType := value.Type()
+	if valueType == timeType || (value.CanInterface() && isGoStringer(value)) {
+		return fmt.Sprintf("%#v", value)
+	}
+	// 嵌套的http.Header, 如http.Response.Header
+	if valueType == headerType && value.CanInterface() {
+		redacted, headerChanged := r.redactHeader(value.Interface().(http.Header))
+		if headerChanged {
+			*changed = true
+		}
+		return fmt.Sprintf("%#v", redacted)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() || value.Elem().Kind() != reflect.Struct {
+			return fmt.Sprintf("%#v", value)
+		}
+		return "&" + r.formatValue(value.Elem(), path, depth+1, changed)
+	case reflect.Interface:
+		if value.IsNil() {
+			return fmt.Sprintf("%#v", value)
+		}
+		return r.formatValue(value.Elem(), path, depth+1, changed)
+	case reflect.Struct:
+		builder := new(strings.Builder)
+		builder.WriteString(valueType.String() + "{")
+		written := 0
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			tag := field.Tag.Get("log")
+			if tag == "-" {
+				*changed = true
+				continue
+			}
+			if written > 0 {
+				builder.WriteString(", ")
+			}
+			written++
+			builder.WriteString(field.Name + ":")
+			childPath := append(path[:len(path):len(path)], strings.ToLower(jsonFieldName(field)))
+			if tag == "mask" || r.matchPath(childPath, true) || r.jsonKeys[strings.ToLower(field.Name)] {
+				builder.WriteString(strconv.Quote(r.mask))
+				*changed = true
+				continue
+			}
+			builder.WriteString(r.formatValue(value.Field(i), childPath, depth+1, changed))
+		}
+		builder.WriteString("}")
+		return builder.String()
+	case reflect.Map:
+		if value.IsNil() || valueType.Key().Kind() != reflect.String {
+			return fmt.Sprintf("%#v", value)
+		}
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, k int) bool {
+			return keys[i].String() < keys[k].String()
+		})
+		builder := new(strings.Builder)
+		builder.WriteString(valueType.String() + "{")
+		for i, key := range keys {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(fmt.Sprintf("%#v:", key))
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key.String()))
+			if r.matchPath(childPath, true) {
+				builder.WriteString(strconv.Quote(r.mask))
+				*changed = true
+				continue
+			}
+			builder.WriteString(r.formatValue(value.MapIndex(key), childPath, depth+1, changed))
+		}
+		builder.WriteString("}")
+		return builder.String()
+	case reflect.Slice, reflect.Array:
+		if (value.Kind() == reflect.Slice && value.IsNil()) || valueType.Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("%#v", value)
+		}
+		builder := new(strings.Builder)
+		builder.WriteString(valueType.String() + "{")
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			childPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if r.matchPath(childPath, false) {
+				builder.WriteString(strconv.Quote(r.mask))
+				*changed = true
+				continue
+			}
+			builder.WriteString(r.formatValue(value.Index(i), childPath, depth+1, changed))
+		}
+		builder.WriteString("}")
+		return builder.String()
+	}
+	return fmt.Sprintf("%#v", value)
+}
+
+func isGoStringer(value reflect.Value) bool {
+	_, ok := value.Interface().(fmt.GoStringer)
+	return ok
+}
+
+// jsonFieldName json tag中的名称, 没有时使用字段名
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+var logTagCache sync.Map // reflect.Type -> bool
+
+// hasLogTag 类型中(包括嵌套的类型)是否有字段设置了log tag, 或者包含需要隐藏敏感请求头的http.Header
+func hasLogTag(t reflect.Type) bool {
+	if cached, ok := logTagCache.Load(t); ok {
+		return cached.(bool)
+	}
+	result := typeHasLogTag(t, make(map[reflect.Type]bool))
+	logTagCache.Store(t, result)
+	return result
+}
+
+func typeHasLogTag(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	if t == headerType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return typeHasLogTag(t.Elem(), visited)
+	case reflect.Map:
+		return typeHasLogTag(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get("log") != "" || typeHasLogTag(field.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type captureLogger struct {
+	lines []string
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Warnf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+type redactAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password" log:"mask"`
+	Secret   string `log:"-"`
+	Token    string `json:"access_token"`
+	Cards    []redactCard
+}
+
+type redactCard struct {
+	Number string `json:"number"`
+	Bank   string `json:"bank"`
+}
+
+func TestRedactJSON(t *testing.T) {
+	r := newRedactor(RedactJSONKeys("password", "Access_Token"), RedactJSONPaths("cards.*.number", "list.1"))
+	data := `{"name":"shen","password":"p","nested":{"ACCESS_TOKEN":{"a":1}},"cards":[{"number":"4111","bank":"x"}],"list":[1,2,3],"html":"<a>"}`
+	redacted, changed := r.redactJSON([]byte(data))
+	expect := `{"name":"shen","password":"***","nested":{"ACCESS_TOKEN":"***"},"cards":[{"number":"***","bank":"x"}],"list":[1,"***",3],"html":"<a>"}`
+	if !changed || string(redacted) != expect {
+		t.Errorf("\n%s\n%s", redacted, expect)
+	}
+
+	// 没有匹配时保持原样(包括格式)
+	data = "{\n  \"name\": \"shen\"\n}"
+	if redacted, changed = r.redactJSON([]byte(data)); changed || string(redacted) != data {
+		t.Error(string(redacted))
+	}
+	if _, changed = r.redactJSON([]byte(`{"password":`)); changed {
+		t.Error("invalid json should not be changed")
+	}
+}
+
+func TestRedactArg(t *testing.T) {
+	account := &redactAccount{Name: "shen", Password: "p", Secret: "s", Token: "t", Cards: []redactCard{{Number: "4111", Bank: "x"}}}
+
+	r := newRedactor()
+	expect := `&tools.redactAccount{Name:"shen", Password:"***", Token:"t", Cards:[]tools.redactCard{tools.redactCard{Number:"4111", Bank:"x"}}}`
+	if result := r.redactArg(account); result != expect {
+		t.Errorf("\n%s\n%s", result, expect)
+	}
+
+	r = newRedactor(RedactJSONKeys("access_token"), RedactJSONPaths("cards.*.number"))
+	expect = `tools.redactAccount{Name:"shen", Password:"***", Token:"***", Cards:[]tools.redactCard{tools.redactCard{Number:"***", Bank:"x"}}}`
+	if result := r.redactArg(*account); result != expect {
+		t.Errorf("\n%s\n%s", result, expect)
+	}
+
+	// 没有需要脱敏的内容时返回原值
+	card := redactCard{Number: "1"}
+	if result := r.redactArg(card); result != card {
+		t.Error(result)
+	}
+	values := map[string][]string{"access_token": {"t"}, "id": {"1"}}
+	if result := r.redactArg(values); result != `map[string][]string{"access_token":"***", "id":[]string{"1"}}` {
+		t.Error(result)
+	}
+
+	header := http.Header{"Authorization": {"Bearer t"}, "Accept": {"*/*"}}
+	result := r.redactArg(header).(http.Header)
+	if result.Get("Authorization") != "***" || result.Get("Accept") != "*/*" || header.Get("Authorization") != "Bearer t" {
+		t.Error(result, header)
+	}
+}
+
+func TestRedactPatterns(t *testing.T) {
+	r := newRedactor(RedactEmails(), RedactCardNumbers(), RedactPattern(regexp.MustCompile(`(token=)\w+`), "${1}***"))
+	result := r.redactString("mail shen@example.com card 4111 1111 1111 1234 url /a?token=abc123&id=1")
+	expect := "mail ***@example.com card ************1234 url /a?token=***&id=1"
+	if result != expect {
+		t.Errorf("\n%s\n%s", result, expect)
+	}
+}
+
+func TestRedactLog(t *testing.T) {
+	capture := new(captureLogger)
+	SetLogger(capture)
+	SetRedaction(RedactJSONKeys("password"), RedactEmails())
+	defer func() {
+		SetLogger(nil)
+		SetRedaction()
+	}()
+
+	Logln(`{"user":"shen@example.com","password":"p"}`, redactAccount{Name: "n", Password: "p", Secret: "s"})
+	Infof("%s %v\n", "to shen@example.com", redactCard{Number: "1"})
+	if len(capture.lines) != 2 {
+		t.Fatal(capture.lines)
+	}
+	if !strings.Contains(capture.lines[0], `{"user":"***@example.com","password":"***"}, tools.redactAccount{Name:"n", Password:"***", Token:"", Cards:[]tools.redactCard(nil)}`) {
+		t.Error(capture.lines[0])
+	}
+	if !strings.HasSuffix(capture.lines[1], "to ***@example.com {1 }\n") {
+		t.Error(capture.lines[1])
+	}
+}
+
+func TestRedactDump(t *testing.T) {
+	SetRedaction(RedactJSONKeys("password"), RedactHeaders("X-Api-Key"))
+	defer SetRedaction()
+
+	request, _ := http.NewRequest(http.MethodPost, "https://example.com/login", strings.NewReader(`{"name":"shen","password":"p"}`))
+	request.Header.Set("X-Api-Key", "key")
+	dumped, err := DumpRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dumped, "X-Api-Key: ***") || !strings.HasSuffix(dumped, `{"name":"shen","password":"***"}`) {
+		t.Error(dumped)
+	}
+}
+
+func TestRedactResponseLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+	}))
+	defer server.Close()
+
+	capture := new(captureLogger)
+	SetLogger(capture)
+	defer func() {
+		SetLogger(nil)
+		SetRedaction()
+	}()
+
+	// 有json规则时会按formatValue格式化, 没有时按原格式打印
+	for _, options := range [][]RedactOptionFunc{{RedactJSONKeys("password")}, nil} {
+		SetRedaction(options...)
+		capture.lines = nil
+		response := new(http.Response)
+		if err := Get(server.URL, nil, response, NetLogLevelOption(NetLogResponse)); err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		log := strings.Join(capture.lines, "\n")
+		if strings.Contains(log, "secret-session") || !strings.Contains(log, "***") {
+			t.Error(log)
+		}
+		if response.Header.Get("Set-Cookie") != "session=secret-session" {
+			t.Error(response.Header)
+		}
+	}
+
+	// 嵌套的http.Header
+	type wrapper struct {
+		Header   http.Header
+		Password string
+	}
+	r := newRedactor(RedactJSONKeys("password"))
+	result := r.redactArg(wrapper{Header: http.Header{"Cookie": {"c"}}, Password: "p"})
+	if result != `tools.wrapper{Header:http.Header{"Cookie":[]string{"***"}}, Password:"***"}` {
+		t.Error(result)
+	}
+}
+
+func TestRedactDefaultRules(t *testing.T) {
+	capture := new(captureLogger)
+	SetLogger(capture)
+	SetRedaction()
+	defer SetLogger(nil)
+
+	response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Set-Cookie": {"session=secret-session"}}}
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	request.Header.Set("Authorization", "Bearer secret-token")
+	type wrapper struct {
+		Header http.Header
+	}
+	Logln(response, request, wrapper{Header: http.Header{"Cookie": {"secret-cookie"}}})
+	log := strings.Join(capture.lines, "\n")
+	if strings.Contains(log, "secret") || strings.Count(log, "***") != 3 {
+		t.Error(log)
+	}
+	// 不修改原值
+	if response.Header.Get("Set-Cookie") != "session=secret-session" || request.Header.Get("Authorization") != "Bearer secret-token" {
+		t.Error(response.Header, request.Header)
+	}
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionJar 在cookiejar的基础上记录完整的cookie(包括domain/path/expires等), 用于查看和持久化
+type sessionJar struct {
+	jar     *cookiejar.Jar
+	lock    sync.Mutex
+	cookies map[string]SessionCookie // key: host + path + name
+}
+
+// SessionCookie 记录的cookie及其来源URL
+type SessionCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	// 保存完整的请求url(不含query), 没有Path的cookie加载后仍使用原来的默认path
+	source := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+	for _, cookie := range cookies {
+		domain := cookie.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		path := cookie.Path
+		if path == "" || path[0] != '/' {
+			path = defaultCookiePath(u.Path)
+		}
+		key := domain + path + ";" + cookie.Name
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+		// 只记录cookiejar接受了的cookie(domain不匹配, 公共后缀等会被拒绝)
+		if !j.accepted(u, path, cookie) {
+			continue
+		}
+		// 通过Max-Age设置的过期时间转换为Expires, 便于持久化
+		if cookie.MaxAge > 0 {
+			copied := *cookie
+			copied.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+			copied.MaxAge = 0
+			cookie = &copied
+		}
+		j.cookies[key] = SessionCookie{URL: source.String(), Cookie: cookie}
+	}
+}
+
+// accepted cookiejar是否保存了cookie, 即请求cookie所在的path时会带上它
+func (j *sessionJar) accepted(u *url.URL, path string, cookie *http.Cookie) bool {
+	target := url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}
+	if cookie.Secure {
+		target.Scheme = "https"
+	}
+	for _, stored := range j.jar.Cookies(&target) {
+		if stored.Name == cookie.Name && stored.Value == cookie.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultCookiePath RFC 6265 5.1.4, 没有Path属性的cookie的默认path为请求path所在的目录
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if path == "" || path[0] != '/' || i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// all 未过期的cookie, 按URL和名称排序
+func (j *sessionJar) all() []SessionCookie {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	now := time.Now()
+	result := make([]SessionCookie, 0, len(j.cookies))
+	for key, cookie := range j.cookies {
+		if !cookie.Cookie.Expires.IsZero() && cookie.Cookie.Expires.Before(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		result = append(result, cookie)
+	}
+	sort.Slice(result, func(i, k int) bool {
+		if result[i].URL != result[k].URL {
+			return result[i].URL < result[k].URL
+		}
+		return result[i].Cookie.Name < result[k].Cookie.Name
+	})
+	return result
+}
+
+// Session 保持cookie和默认请求头, 适用于需要登录的场景, 并发安全
+type Session struct {
+	client  *http.Client
+	jar     *sessionJar
+	options []NetOptionFunc
+
+	lock   sync.RWMutex
+	header http.Header
+}
+
+// NewSession 创建Session, options会作用于该Session的每个请求, 单次请求的options优先级更高
+//
+// 注意: 没有使用公共后缀列表(PublicSuffixList), 不会拒绝domain为公共后缀(如"co.uk")的cookie,
+// 只应该用来访问可信的站点
+func NewSession(options ...NetOptionFunc) *Session {
+	// 不传PublicSuffixList时不会出错
+	jar, _ := cookiejar.New(nil)
+	sessionJar := &sessionJar{jar: jar, cookies: make(map[string]SessionCookie)}
+	return &Session{
+		client:  &http.Client{Jar: sessionJar},
+		jar:     sessionJar,
+		options: options,
+		header:  make(http.Header),
+	}
+}
+
+// SetHeader 设置默认请求头, 单次请求通过NetHeader设置的同名请求头会覆盖默认值
+func (s *Session) SetHeader(key string, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.header.Set(key, value)
+}
+
+// DelHeader 删除默认请求头
+func (s *Session) DelHeader(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.header.Del(key)
+}
+
+// Header 默认请求头的副本
+func (s *Session) Header() http.Header {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.header.Clone()
+}
+
+// Cookies 请求rawURL时会带上的cookie(只有name和value)
+func (s *Session) Cookies(rawURL string) []*http.Cookie {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return s.jar.Cookies(u)
+}
+
+// AllCookies 收到的所有未过期的cookie, 包含domain/path/expires等完整信息
+func (s *Session) AllCookies() []SessionCookie {
+	return s.jar.all()
+}
+
+// SetCookies 手动设置cookie, 相当于从rawURL收到了这些cookie
+func (s *Session) SetCookies(rawURL string, cookies ...*http.Cookie) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	s.jar.SetCookies(u, cookies)
+	return nil
+}
+
+// SaveCookies 将未过期的cookie保存为json文件(会话cookie也会保存), 新建的文件只有所有者可读写(0600)
+func (s *Session) SaveCookies(path string) error {
+	return saveJSON(path, s.AllCookies(), "  ", 0600)
+}
+
+// LoadCookies 从SaveCookies保存的文件中加载cookie
+func (s *Session) LoadCookies(path string) error {
+	cookies, err := LoadJSON[[]SessionCookie](path)
+	if err != nil {
+		return err
+	}
+	for _, cookie := range cookies {
+		if err = s.SetCookies(cookie.URL, cookie.Cookie); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withSession session的options在前, 单次请求的options在后, 最后合并默认请求头
+func (s *Session) withSession(options []NetOptionFunc) []NetOptionFunc {
+	header := s.Header()
+	merged := make([]NetOptionFunc, 0, len(s.options)+len(options)+2)
+	merged = append(merged, NetHTTPClient(s.client))
+	merged = append(merged, s.options...)
+	merged = append(merged, options...)
+	return append(merged, func(o *netOptions) {
+		for key, values := range o.Header {
+			header[key] = values
+		}
+		o.Header = header
+		// 通过NetHTTPClient指定了其他client(如NewHTTPClient创建的)时, 仍使用Session的cookie
+		if o.Client != s.client && o.Client != nil && o.Client.Jar == nil {
+			client := *o.Client
+			client.Jar = s.jar
+			o.Client = &client
+		}
+		// 多了一层Session方法的调用
+		o.LogCallerSkip++
+		o.LogLineSkip++
+	})
+}
+
+// Get 同tools.Get, 使用Session的cookie和默认配置
+func (s *Session) Get(urlStr string, values url.Values, obj interface{}, options ...NetOptionFunc) error {
+	return Get(urlStr, values, obj, s.withSession(options)...)
+}
+
+// Delete 同tools.Delete, 使用Session的cookie和默认配置
+func (s *Session) Delete(urlStr string, values url.Values, obj interface{}, options ...NetOptionFunc) error {
+	return Delete(urlStr, values, obj, s.withSession(options)...)
+}
+
+// Post 同tools.Post, 使用Session的cookie和默认配置
+func (s *Session) Post(url string, data interface{}, obj interface{}, options ...NetOptionFunc) error {
+	return Post(url, data, obj, s.withSession(options)...)
+}
+
+// Put 同tools.Put, 使用Session的cookie和默认配置
+func (s *Session) Put(url string, data interface{}, obj interface{}, options ...NetOptionFunc) error {
+	return Put(url, data, obj, s.withSession(options)...)
+}
+
+// Patch 同tools.Patch, 使用Session的cookie和默认配置
+func (s *Session) Patch(url string, data interface{}, obj interface{}, options ...NetOptionFunc) error {
+	return Patch(url, data, obj, s.withSession(options)...)
+}
+
+// FormDataPost 同tools.FormDataPost, 使用Session的cookie和默认配置
+func (s *Session) FormDataPost(url string, data map[string]string, obj interface{}, options ...NetOptionFunc) error {
+	return FormDataPost(url, data, obj, s.withSession(options)...)
+}
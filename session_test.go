@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Value: "1", Path: "/"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Path: "/", MaxAge: -1})
+		}
+		sid, _ := r.Cookie("sid")
+		if sid == nil {
+			sid = &http.Cookie{}
+		}
+		fmt.Fprintf(w, `{"title":"%s","body":"%s"}`, sid.Value, r.Header.Get("X-Client")+r.Header.Get("X-Trace"))
+	}))
+	defer server.Close()
+
+	session := NewSession(NetLogLevelOption(NetLogNone))
+	session.SetHeader("X-Client", "go-tools")
+	user := new(User)
+
+	if err := session.Post(server.URL+"/login", nil, user); err != nil || user.Title != "" {
+		t.Fatal(err, user)
+	}
+	header := http.Header{}
+	header.Set("X-Trace", "-1")
+	if err := session.Get(server.URL+"/me", nil, user, NetHeader(header)); err != nil || user.Title != "abc" || user.Body != "go-tools-1" {
+		t.Fatal(err, user)
+	}
+	if len(header) != 1 {
+		t.Fatal("caller header should not be modified", header)
+	}
+	if cookies := session.Cookies(server.URL); len(cookies) != 2 {
+		t.Fatal(cookies)
+	}
+
+	session.Get(server.URL+"/logout", nil, nil)
+	all := session.AllCookies()
+	if len(all) != 1 || all[0].Cookie.Name != "sid" || all[0].Cookie.Expires.IsZero() {
+		t.Fatal(all)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := session.SaveCookies(path); err != nil {
+		t.Fatal(err)
+	}
+	// cookie属于敏感信息, 只有所有者可读写
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatal(err, info.Mode())
+	}
+	restored := NewSession(NetLogLevelOption(NetLogNone))
+	if err := restored.LoadCookies(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Get(server.URL+"/me", nil, user); err != nil || user.Title != "abc" || user.Body != "" {
+		t.Fatal(err, user)
+	}
+
+	// 不同Session之间cookie互不影响
+	if err := Get(server.URL+"/me", nil, user, NetLogLevelOption(NetLogNone)); err != nil || user.Title != "" {
+		t.Fatal(err, user)
+	}
+}
+
+func TestSessionCookiePath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/login" {
+			// 没有Path, 默认为/app
+			http.SetCookie(w, &http.Cookie{Name: "app", Value: "1", MaxAge: 3600})
+			// domain不匹配, 会被cookiejar拒绝
+			http.SetCookie(w, &http.Cookie{Name: "evil", Value: "1", Domain: "example.com", Path: "/", MaxAge: 3600})
+		}
+	}))
+	defer server.Close()
+
+	session := NewSession(NetLogLevelOption(NetLogNone))
+	if err := session.Get(server.URL+"/app/login", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	all := session.AllCookies()
+	if len(all) != 1 || all[0].Cookie.Name != "app" || all[0].URL != server.URL+"/app/login" {
+		t.Fatal(all)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := session.SaveCookies(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewSession(NetLogLevelOption(NetLogNone))
+	if err := restored.LoadCookies(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Cookies(server.URL+"/app/me")) != 1 || len(restored.Cookies(server.URL+"/other")) != 0 {
+		t.Error(restored.AllCookies())
+	}
+}
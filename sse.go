@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent Server-Sent Events的一个事件
+type SSEEvent struct {
+	ID    string // 最近一次收到的id, 没有时为空
+	Event string // 事件类型, default: "message"
+	Data  string // 多行data以"\n"拼接
+}
+
+// SSEOptionFunc SubscribeSSE配置
+type SSEOptionFunc func(o *sseOptions)
+
+type sseOptions struct {
+	NetOptions  []NetOptionFunc
+	Backoff     time.Duration // 重连的初始间隔, 服务端通过retry字段设置后使用服务端的值, default: 1s
+	MaxBackoff  time.Duration // 重连的最大间隔, 连续失败时间隔翻倍, default: 30s
+	MaxRetries  int           // 连续重连失败的最大次数, 连接成功后重新计数, 小于0不限制, default: -1
+	LastEventID string
+	BufferSize  int // 事件channel的缓冲区大小, default: 0
+}
+
+// SSENetOptions 建立连接时使用的配置, 如请求头/鉴权/client等, 注意不要设置Timeout, 否则连接会在超时后断开重连
+func SSENetOptions(options ...NetOptionFunc) SSEOptionFunc {
+	return func(o *sseOptions) {
+		o.NetOptions = append(o.NetOptions, options...)
+	}
+}
+
+// SSEBackoff 重连的初始间隔和最大间隔
+func SSEBackoff(backoff time.Duration, maxBackoff time.Duration) SSEOptionFunc {
+	return func(o *sseOptions) {
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// SSEMaxRetries 连续重连失败的最大次数, 超过后errs会收到最后一次的错误, 小于0不限制
+func SSEMaxRetries(maxRetries int) SSEOptionFunc {
+	return func(o *sseOptions) {
+		o.MaxRetries = maxRetries
+	}
+}
+
+// SSELastEventID 首次连接时发送的Last-Event-ID, 用于从上次断开的位置继续
+func SSELastEventID(id string) SSEOptionFunc {
+	return func(o *sseOptions) {
+		o.LastEventID = id
+	}
+}
+
+// SSEBufferSize 事件channel的缓冲区大小
+func SSEBufferSize(size int) SSEOptionFunc {
+	return func(o *sseOptions) {
+		o.BufferSize = size
+	}
+}
+
+// SSEStatusError 服务端返回了非200的状态码, 或Content-Type不是text/event-stream
+type SSEStatusError struct {
+	StatusCode  int
+	ContentType string
+}
+
+func (e *SSEStatusError) Error() string {
+	return fmt.Sprintf("sse: unexpected response, status %d, content type %q", e.StatusCode, e.ContentType)
+}
+
+// retryable 5xx和429可以重连
+func (e *SSEStatusError) retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+// SubscribeSSE 订阅Server-Sent Events, 连接断开后按Last-Event-ID自动重连, 事件通过events返回.
+// ctx取消, 服务端返回204, 或出现不可恢复的错误(4xx, 超过SSEMaxRetries)时结束, 两个channel都会关闭,
+// 因错误结束时errs会收到该错误, ctx取消和204不会返回错误
+func SubscribeSSE(ctx context.Context, url string, options ...SSEOptionFunc) (events <-chan SSEEvent, errs <-chan error) {
+	o := sseOptions{Backoff: time.Second, MaxBackoff: 30 * time.Second, MaxRetries: -1}
+	for _, option := range options {
+		option(&o)
+	}
+	eventChan := make(chan SSEEvent, o.BufferSize)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer close(eventChan)
+		if err := o.run(ctx, url, eventChan); err != nil && ctx.Err() == nil {
+			errChan <- err
+		}
+	}()
+	return eventChan, errChan
+}
+
+func (o *sseOptions) run(ctx context.Context, url string, events chan<- SSEEvent) error {
+	state := &sseState{lastEventID: o.LastEventID, retry: o.Backoff}
+	failures := 0
+	for {
+		connected, err := o.connect(ctx, url, state, events)
+		if ctx.Err() != nil || errors.Is(err, errSSENoContent) {
+			return nil
+		}
+		var statusErr *SSEStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return err
+		}
+		if connected {
+			failures = 0
+		} else {
+			failures++
+			if o.MaxRetries >= 0 && failures > o.MaxRetries {
+				return err
+			}
+		}
+
+		timer := time.NewTimer(backoffDelay(state.retry, o.MaxBackoff, failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay 第failures次连续失败后的重连间隔, 从base开始翻倍, 不超过max(max为0时不限制)
+func backoffDelay(base time.Duration, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+var errSSENoContent = errors.New("sse: no content")
+
+// connect 建立一次连接并读取事件, connected为true代表连接成功(收到了200)
+func (o *sseOptions) connect(ctx context.Context, url string, state *sseState, events chan<- SSEEvent) (connected bool, err error) {
+	options := append([]NetOptionFunc{LogCallerSkipOption(-2), LogLineSkipOption(-2)}, o.NetOptions...)
+	config := configWithOptions(append(options, NetContext(ctx))...)
+	config.Method = http.MethodGet
+	config.URL = url
+	// 事件流没有结束, 不能完整打印或缓存
+	config.NetLogLevel &^= NetLogDump
+	config.CacheMode = CacheBypass
+	header := make(http.Header)
+	if config.Header != nil {
+		header = config.Header.Clone()
+	}
+	header.Set("Accept", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	if state.lastEventID != "" {
+		header.Set("Last-Event-ID", state.lastEventID)
+	}
+	config.Header = header
+
+	response := new(http.Response)
+	if err = request(response, config); err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNoContent {
+		return false, errSSENoContent
+	}
+	contentType := response.Header.Get("Content-Type")
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(strings.ToLower(contentType), "text/event-stream") {
+		return false, &SSEStatusError{StatusCode: response.StatusCode, ContentType: contentType}
+	}
+
+	err = state.read(response.Body, func(event SSEEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return true, err
+}
+
+// ReadSSE 从r中解析Server-Sent Events, 每个事件回调一次fn, 直到r结束或fn返回错误, r正常结束时返回nil
+func ReadSSE(r io.Reader, fn func(event SSEEvent) error) error {
+	return new(sseState).read(r, fn)
+}
+
+// sseState 重连时需要保留的状态
+type sseState struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+// read 按https://html.spec.whatwg.org/multipage/server-sent-events.html 解析, 未以空行结束的事件会被丢弃
+func (s *sseState) read(r io.Reader, fn func(event SSEEvent) error) error {
+	reader := bufio.NewReader(r)
+	eventType := ""
+	data := new(strings.Builder)
+	hasData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if hasData {
+				event := SSEEvent{ID: s.lastEventID, Event: eventType, Data: strings.TrimSuffix(data.String(), "\n")}
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				if err = fn(event); err != nil {
+					return err
+				}
+			}
+			eventType = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if index := strings.IndexByte(line, ':'); index >= 0 {
+			field, value = line[:index], strings.TrimPrefix(line[index+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value + "\n")
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.retry = time.Duration(milliseconds) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := ": comment\n" +
+		"data: first\n\n" +
+		"event: update\r\nid: 1\r\ndata: line1\r\ndata:line2\r\n\r\n" +
+		"id: 2\nretry: 100\n\n" + // 没有data不会分发, 但会更新id
+		"data\n\n" +
+		"data: incomplete"
+	var events []SSEEvent
+	state := new(sseState)
+	err := state.read(strings.NewReader(stream), func(event SSEEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []SSEEvent{
+		{Event: "message", Data: "first"},
+		{ID: "1", Event: "update", Data: "line1\nline2"},
+		{ID: "2", Event: "message", Data: ""},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expect) {
+		t.Errorf("\n%v\n%v", events, expect)
+	}
+	if state.lastEventID != "2" || state.retry != 100*time.Millisecond {
+		t.Error(state)
+	}
+
+	stop := errors.New("stop")
+	if err = ReadSSE(strings.NewReader(stream), func(SSEEvent) error { return stop }); err != stop {
+		t.Error(err)
+	}
+}
+
+func TestSubscribeSSE(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		switch n {
+		case 1:
+			if r.Header.Get("Last-Event-ID") != "0" || r.Header.Get("Accept") != "text/event-stream" {
+				t.Error(r.Header)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: a\n\nid: 2\ndata: b\n\n")
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 3:
+			if r.Header.Get("Last-Event-ID") != "2" {
+				t.Error(r.Header)
+			}
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			fmt.Fprint(w, "id: 3\nevent: done\ndata: c\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	events, errs := SubscribeSSE(context.Background(), server.URL, SSELastEventID("0"),
+		SSENetOptions(NetLogLevelOption(NetLogNone)), SSEBackoff(10*time.Millisecond, 50*time.Millisecond))
+	var result []string
+	for event := range events {
+		result = append(result, event.ID+":"+event.Event+":"+event.Data)
+	}
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+	if strings.Join(result, ",") != "1:message:a,2:message:b,3:done:c" || atomic.LoadInt32(&connections) != 4 {
+		t.Error(result, connections)
+	}
+}
+
+func TestSubscribeSSEError(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	options := []SSEOptionFunc{SSENetOptions(NetLogLevelOption(NetLogNone)), SSEBackoff(time.Millisecond, 5*time.Millisecond)}
+	events, errs := SubscribeSSE(context.Background(), server.URL+"/forbidden", options...)
+	for range events {
+	}
+	var statusErr *SSEStatusError
+	if err := <-errs; !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden || atomic.LoadInt32(&connections) != 1 {
+		t.Error(err, connections)
+	}
+
+	atomic.StoreInt32(&connections, 0)
+	events, errs = SubscribeSSE(context.Background(), server.URL, append(options, SSEMaxRetries(2))...)
+	for range events {
+	}
+	if err := <-errs; !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway || atomic.LoadInt32(&connections) != 3 {
+		t.Error(err, connections)
+	}
+
+	// ctx取消时不返回错误
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	events, errs = SubscribeSSE(ctx, server.URL, options...)
+	for range events {
+	}
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StopwatchOptionFunc Stopwatch配置
+type StopwatchOptionFunc func(o *stopwatchOptions)
+
+type stopwatchOptions struct {
+	Log          bool
+	LogThreshold time.Duration             // 耗时大于等于该值才打印
+	LogFunc      func(args ...interface{}) // default: Info
+	Stats        *TimeStats                // default: nil, 不统计
+}
+
+// StopwatchLog 开启日志, Stop时耗时大于等于threshold才打印, 0代表总是打印
+func StopwatchLog(threshold time.Duration) StopwatchOptionFunc {
+	return func(o *stopwatchOptions) {
+		o.Log = true
+		o.LogThreshold = threshold
+	}
+}
+
+// StopwatchLogFunc 日志输出方法, 可传Debug/Info/Warn/Error, default: Info
+func StopwatchLogFunc(logFunc func(args ...interface{})) StopwatchOptionFunc {
+	return func(o *stopwatchOptions) {
+		o.LogFunc = logFunc
+	}
+}
+
+// StopwatchStats 耗时统计到stats, 可传DefaultTimeStats, default: nil, 不统计
+func StopwatchStats(stats *TimeStats) StopwatchOptionFunc {
+	return func(o *stopwatchOptions) {
+		o.Stats = stats
+	}
+}
+
+// Lap 分段耗时
+type Lap struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Stopwatch 计时器, 支持分段(Lap)和嵌套(Span), 并发安全
+type Stopwatch struct {
+	name    string
+	options stopwatchOptions
+
+	lock     sync.Mutex
+	start    time.Time
+	lapStart time.Time
+	laps     []Lap
+	spans    []*Stopwatch
+	elapsed  time.Duration
+	stopped  bool
+}
+
+// NewStopwatch 创建并开始计时
+func NewStopwatch(name string, options ...StopwatchOptionFunc) *Stopwatch {
+	o := stopwatchOptions{LogFunc: Info}
+	for _, option := range options {
+		option(&o)
+	}
+	if o.LogFunc == nil {
+		o.LogFunc = Info
+	}
+
+	now := time.Now()
+	return &Stopwatch{name: name, options: o, start: now, lapStart: now}
+}
+
+// Name 名称, 嵌套的Stopwatch名称为"parent/name"
+func (s *Stopwatch) Name() string {
+	return s.name
+}
+
+// Lap 记录从上一个Lap(或开始)到现在的耗时, 统计名称为"name/lapName"
+func (s *Stopwatch) Lap(lapName string) time.Duration {
+	s.lock.Lock()
+	now := time.Now()
+	lap := Lap{Name: lapName, Duration: now.Sub(s.lapStart)}
+	s.lapStart = now
+	s.laps = append(s.laps, lap)
+	s.lock.Unlock()
+
+	if s.options.Stats != nil {
+		s.options.Stats.Record(s.name+"/"+lapName, lap.Duration)
+	}
+	return lap.Duration
+}
+
+// Laps 已记录的分段耗时
+func (s *Stopwatch) Laps() []Lap {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Lap(nil), s.laps...)
+}
+
+// Span 创建嵌套的子Stopwatch, 继承当前配置, 名称为"name/spanName"
+func (s *Stopwatch) Span(spanName string) *Stopwatch {
+	span := &Stopwatch{name: s.name + "/" + spanName, options: s.options}
+	span.start = time.Now()
+	span.lapStart = span.start
+
+	s.lock.Lock()
+	s.spans = append(s.spans, span)
+	s.lock.Unlock()
+	return span
+}
+
+// Elapsed 已耗时, 已Stop则返回总耗时
+func (s *Stopwatch) Elapsed() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return s.elapsed
+	}
+	return time.Since(s.start)
+}
+
+// Stop 停止计时并返回总耗时, 计入统计, 开启日志时按阈值打印; 重复调用返回第一次Stop时的耗时
+func (s *Stopwatch) Stop() time.Duration {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return s.elapsed
+	}
+	s.stopped = true
+	s.elapsed = time.Since(s.start)
+	s.lock.Unlock()
+
+	if s.options.Stats != nil {
+		s.options.Stats.Record(s.name, s.elapsed)
+	}
+	if s.options.Log && s.elapsed >= s.options.LogThreshold {
+		s.options.LogFunc(LogCallerSkip(1), s.String())
+	}
+	return s.elapsed
+}
+
+// String 耗时概要, 包括分段和嵌套的耗时
+func (s *Stopwatch) String() string {
+	builder := new(strings.Builder)
+	s.writeTo(builder, "")
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func (s *Stopwatch) writeTo(builder *strings.Builder, indent string) {
+	s.lock.Lock()
+	laps := append([]Lap(nil), s.laps...)
+	spans := append([]*Stopwatch(nil), s.spans...)
+	s.lock.Unlock()
+
+	fmt.Fprintf(builder, "%s%s time cost = %v\n", indent, s.name, s.Elapsed())
+	for _, lap := range laps {
+		fmt.Fprintf(builder, "%s  - %s: %v\n", indent, lap.Name, lap.Duration)
+	}
+	for _, span := range spans {
+		span.writeTo(builder, indent+"  ")
+	}
+}
+
+// DefaultTimeStats 全局共享的耗时统计, 需要通过StopwatchStats(DefaultTimeStats)显式开启;
+// 每个名称最多保留1000个样本(约8KB), 名称不会被清除, 不要使用请求id等无限增长的名称
+var DefaultTimeStats = NewTimeStats(1000)
+
+// TimeStat 某个名称的耗时统计
+type TimeStat struct {
+	Name  string
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Avg   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+type timeSamples struct {
+	count   int
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	samples []time.Duration // 环形缓冲, 用于计算分位数
+	next    int
+}
+
+// TimeStats 按名称聚合耗时, 并发安全
+type TimeStats struct {
+	lock       sync.Mutex
+	maxSamples int
+	stats      map[string]*timeSamples
+}
+
+// NewTimeStats maxSamples: 每个名称最多保留的样本数, 用于计算分位数, count/min/max/avg不受影响
+func NewTimeStats(maxSamples int) *TimeStats {
+	if maxSamples < 1 {
+		maxSamples = 1
+	}
+	return &TimeStats{maxSamples: maxSamples, stats: make(map[string]*timeSamples)}
+}
+
+// Record 记录一次耗时
+func (t *TimeStats) Record(name string, duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stat, ok := t.stats[name]
+	if !ok {
+		stat = &timeSamples{min: duration, max: duration}
+		t.stats[name] = stat
+	}
+	stat.count++
+	stat.total += duration
+	if duration < stat.min {
+		stat.min = duration
+	}
+	if duration > stat.max {
+		stat.max = duration
+	}
+	if len(stat.samples) < t.maxSamples {
+		stat.samples = append(stat.samples, duration)
+	} else {
+		stat.samples[stat.next] = duration
+		stat.next = (stat.next + 1) % t.maxSamples
+	}
+}
+
+// Stat 获取某个名称的统计
+func (t *TimeStats) Stat(name string) (TimeStat, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	stat, ok := t.stats[name]
+	if !ok {
+		return TimeStat{Name: name}, false
+	}
+	return stat.snapshot(name), true
+}
+
+// Snapshot 获取所有统计, 按名称排序
+func (t *TimeStats) Snapshot() []TimeStat {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	result := make([]TimeStat, 0, len(t.stats))
+	for name, stat := range t.stats {
+		result = append(result, stat.snapshot(name))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Reset 清空统计
+func (t *TimeStats) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stats = make(map[string]*timeSamples)
+}
+
+// Dump 统计信息, 每行一个名称
+func (t *TimeStats) Dump() string {
+	builder := new(strings.Builder)
+	for _, stat := range t.Snapshot() {
+		fmt.Fprintf(builder, "%s: count=%d min=%v max=%v avg=%v p50=%v p90=%v p99=%v\n",
+			stat.Name, stat.Count, stat.Min, stat.Max, stat.Avg, stat.P50, stat.P90, stat.P99)
+	}
+	return builder.String()
+}
+
+func (s *timeSamples) snapshot(name string) TimeStat {
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1)+0.5)]
+	}
+	return TimeStat{
+		Name:  name,
+		Count: s.count,
+		Min:   s.min,
+		Max:   s.max,
+		Avg:   s.total / time.Duration(s.count),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+	}
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopwatch(t *testing.T) {
+	stats := NewTimeStats(100)
+	stopwatch := NewStopwatch("job", StopwatchStats(stats), StopwatchLog(0), StopwatchLogFunc(Warn))
+
+	time.Sleep(10 * time.Millisecond)
+	if lap := stopwatch.Lap("load"); lap < 10*time.Millisecond {
+		t.Fatal(lap)
+	}
+
+	span := stopwatch.Span("save")
+	time.Sleep(5 * time.Millisecond)
+	span.Lap("encode")
+	spanCost := span.Stop()
+
+	total := stopwatch.Stop()
+	if total < 15*time.Millisecond || total < spanCost || stopwatch.Stop() != total || stopwatch.Elapsed() != total {
+		t.Fatal(total, spanCost)
+	}
+	if laps := stopwatch.Laps(); len(laps) != 1 || laps[0].Name != "load" {
+		t.Fatal(laps)
+	}
+
+	for _, name := range []string{"job", "job/load", "job/save", "job/save/encode"} {
+		if stat, ok := stats.Stat(name); !ok || stat.Count != 1 {
+			t.Error(name, stat)
+		}
+	}
+	Logln(stopwatch.String())
+
+	// 低于阈值不打印, 默认不统计
+	NewStopwatch("quick", StopwatchLog(time.Hour)).Stop()
+	if _, ok := DefaultTimeStats.Stat("quick"); ok {
+		t.Error("stats should be opt-in")
+	}
+	defer TimeCost("TimeCost")()
+}
+
+func TestTimeStats(t *testing.T) {
+	stats := NewTimeStats(10)
+	for i := 1; i <= 100; i++ {
+		stats.Record("req", time.Duration(i)*time.Millisecond)
+	}
+
+	stat, _ := stats.Stat("req")
+	if stat.Count != 100 || stat.Min != time.Millisecond || stat.Max != 100*time.Millisecond || stat.Avg != 50500*time.Microsecond {
+		t.Fatal(stat)
+	}
+	// 只保留最近的10个样本
+	if stat.P50 < 91*time.Millisecond || stat.P99 != 100*time.Millisecond {
+		t.Fatal(stat)
+	}
+	Logln(stats.Dump())
+
+	stats.Reset()
+	if len(stats.Snapshot()) != 0 {
+		t.Fatal("reset failed")
+	}
+}
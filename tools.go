@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -23,12 +25,12 @@ func RemoveDuplicateElementArray(sourceArray []string) []string {
 	return result
 }
 
-// TimeCost @brief：耗时统计函数
+// TimeCost @brief：耗时统计函数, 需要分段/嵌套/统计/按阈值打印时请使用Stopwatch
 func TimeCost(signs ...string) func() {
 	start := time.Now()
 	return func() {
 		tc := time.Since(start)
-		Logln(LogCallerSkip(1), "%v time cost = %v\n", signs, tc)
+		Logln(LogCallerSkip(1), fmt.Sprintf("%v time cost = %v", signs, tc))
 	}
 }
 
@@ -77,14 +79,9 @@ func LoadFile(path string) []byte {
 	return byteValue
 }
 
-// SaveFile 生成文件
+// SaveFile 生成文件, 已存在则覆盖
 func SaveFile(path string, data []byte) {
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0755)
-	if err != nil {
-		Logln(err)
-	}
-	defer file.Close()
-	_, err = file.Write(data)
+	err := writeFileAtomic(path, data, 0755)
 	if err != nil {
 		Logln(err)
 	}
@@ -98,29 +95,36 @@ func MD5(v string) string {
 	return hex.EncodeToString(m.Sum(nil))
 }
 
-var isOnline = true
+// internetMonitor InternetCheck所使用的状态, 不做防抖, 与之前的行为保持一致
+var internetMonitor = NewConnectivityMonitor(MonitorHysteresis(1, 1))
 
-// InternetCheck 网络检测是否在线
-func InternetCheck(netLogLevel NetLogLevel, switchAction ...func(isOnline bool)) bool {
-	res := new(http.Response)
-	err := Get("http://connect.rom.miui.com/generate_204", nil, res, NetLogLevelOption(netLogLevel))
+// internetAction InternetCheck最后一次传入的switchAction, 由订阅internetMonitor的goroutine按顺序调用
+var internetAction struct {
+	sync.Mutex
+	once   sync.Once
+	action func(isOnline bool)
+}
 
-	if err != nil || res.StatusCode != 204 {
-		Logln(LogCondition(err != nil), err)
-		if isOnline {
-			isOnline = false
-			if len(switchAction) > 0 {
-				go switchAction[0](false)
-			}
-		}
-		return false
-	}
+// InternetCheck 网络检测是否在线, switchAction会在在线状态切换时, 在同一个goroutine中按切换的顺序调用,
+// 多次调用时使用最后一次传入的switchAction. 需要定时检测时建议使用ConnectivityMonitor
+func InternetCheck(netLogLevel NetLogLevel, switchAction ...func(isOnline bool)) bool {
+	err := HTTPProbe(defaultProbeURL, http.StatusNoContent, NetLogLevelOption(netLogLevel))(context.Background())
+	Logln(LogCondition(err != nil), err)
 
-	if !isOnline {
-		isOnline = true
-		if len(switchAction) > 0 {
-			go switchAction[0](true)
-		}
+	if len(switchAction) > 0 {
+		internetAction.Lock()
+		internetAction.action = switchAction[0]
+		internetAction.Unlock()
+		internetAction.once.Do(func() {
+			internetMonitor.OnChange(func(online bool) {
+				internetAction.Lock()
+				action := internetAction.action
+				internetAction.Unlock()
+				action(online)
+			})
+		})
 	}
-	return true
+	online := err == nil
+	internetMonitor.record(online)
+	return online
 }
@@ -0,0 +1,349 @@
+// Package toolstest 测试使用go-tools网络请求的代码: 可编程的MockTransport, 以及录制/回放真实请求的Recorder
+package toolstest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	tools "github.com/shenguanjiejie/go-tools/v3"
+)
+
+// Install 将roundTripper设置为tools的默认Transport, 测试结束后恢复为之前的Transport
+func Install(t testing.TB, roundTripper http.RoundTripper) {
+	t.Helper()
+	previous := tools.GetTransport()
+	tools.SetTransport(roundTripper)
+	t.Cleanup(func() {
+		tools.SetTransport(previous)
+	})
+}
+
+// MockTransport 可编程的http.RoundTripper, 按添加顺序匹配规则, 并发安全
+type MockTransport struct {
+	lock      sync.Mutex
+	rules     []*Rule
+	unmatched []string
+}
+
+// NewMockTransport 创建MockTransport, 没有匹配的规则时请求返回错误
+func NewMockTransport() *MockTransport {
+	return new(MockTransport)
+}
+
+// Install 同Install(t, m)
+func (m *MockTransport) Install(t testing.TB) *MockTransport {
+	t.Helper()
+	Install(t, m)
+	return m
+}
+
+// On 添加规则, method为空时匹配所有方法.
+// urlPattern为空时匹配所有url, 否则匹配其中的scheme/host/path(以*结尾时按前缀匹配), 以及其中包含的query参数,
+// eg: "https://api.example.com/users/*?page=1"
+func (m *MockTransport) On(method string, urlPattern string) *Rule {
+	rule := &Rule{mock: m, method: strings.ToUpper(method), times: -1, status: http.StatusOK, header: make(http.Header)}
+	if urlPattern != "" {
+		pattern, err := url.Parse(urlPattern)
+		if err != nil {
+			panic(fmt.Sprintf("toolstest: invalid url pattern %q: %v", urlPattern, err))
+		}
+		rule.pattern = pattern
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.rules = append(m.rules, rule)
+	return rule
+}
+
+// RoundTrip http.RoundTripper
+func (m *MockTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+	}
+
+	m.lock.Lock()
+	var matched *Rule
+	for _, rule := range m.rules {
+		if rule.match(request, body) {
+			matched = rule
+			rule.calls++
+			break
+		}
+	}
+	if matched == nil {
+		m.unmatched = append(m.unmatched, request.Method+" "+request.URL.String())
+	}
+	m.lock.Unlock()
+
+	if matched == nil {
+		return nil, fmt.Errorf("toolstest: no mock for %s %s", request.Method, request.URL)
+	}
+	return matched.respond(request, body)
+}
+
+// Calls 所有规则匹配的请求总数
+func (m *MockTransport) Calls() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	calls := 0
+	for _, rule := range m.rules {
+		calls += rule.calls
+	}
+	return calls
+}
+
+// AssertExpectations 检查通过Times设置了次数的规则是否刚好匹配了该次数, 以及是否有没有匹配任何规则的请求
+func (m *MockTransport) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, rule := range m.rules {
+		if rule.times >= 0 && rule.calls != rule.times {
+			t.Errorf("toolstest: %s expected %d calls, got %d", rule, rule.times, rule.calls)
+		}
+	}
+	for _, request := range m.unmatched {
+		t.Errorf("toolstest: unmatched request %s", request)
+	}
+}
+
+// Rule 一条匹配规则及其响应, 通过链式调用配置
+type Rule struct {
+	mock     *MockTransport
+	method   string
+	pattern  *url.URL
+	headers  http.Header
+	body     []byte
+	jsonBody interface{}
+	matchers []func(request *http.Request, body []byte) bool
+	times    int // 最多匹配的次数, 小于0不限制
+
+	status    int
+	header    http.Header
+	response  []byte
+	err       error
+	responder func(request *http.Request) (*http.Response, error)
+
+	calls int
+}
+
+func (r *Rule) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	pattern := "*"
+	if r.pattern != nil {
+		pattern = r.pattern.String()
+	}
+	return method + " " + pattern
+}
+
+// WithHeader 请求头key包含value时匹配
+func (r *Rule) WithHeader(key string, value string) *Rule {
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	r.headers.Add(key, value)
+	return r
+}
+
+// WithBody 请求体与body完全相同时匹配
+func (r *Rule) WithBody(body string) *Rule {
+	r.body = []byte(body)
+	return r
+}
+
+// WithJSONBody 请求体按json解析后与v相同时匹配, 忽略字段顺序和空白
+func (r *Rule) WithJSONBody(v interface{}) *Rule {
+	r.jsonBody = normalizeJSON(mustMarshal(v))
+	return r
+}
+
+// WithMatcher 自定义匹配, body为请求体
+func (r *Rule) WithMatcher(matcher func(request *http.Request, body []byte) bool) *Rule {
+	r.matchers = append(r.matchers, matcher)
+	return r
+}
+
+// Times 该规则最多匹配n次, 之后的请求会继续匹配后面的规则, AssertExpectations会检查是否刚好匹配了n次
+func (r *Rule) Times(n int) *Rule {
+	r.times = n
+	return r
+}
+
+// Once 同Times(1)
+func (r *Rule) Once() *Rule {
+	return r.Times(1)
+}
+
+// Reply 返回状态码和响应体
+func (r *Rule) Reply(status int, body string) *Rule {
+	r.status = status
+	r.response = []byte(body)
+	return r
+}
+
+// ReplyJSON 返回状态码和v序列化后的json, Content-Type为application/json
+func (r *Rule) ReplyJSON(status int, v interface{}) *Rule {
+	r.status = status
+	r.response = mustMarshal(v)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// ReplyHeader 设置响应头
+func (r *Rule) ReplyHeader(key string, value string) *Rule {
+	r.header.Add(key, value)
+	return r
+}
+
+// ReplyError 请求返回err, 模拟网络错误等
+func (r *Rule) ReplyError(err error) *Rule {
+	r.err = err
+	return r
+}
+
+// ReplyFunc 由responder生成响应, 返回的response.Request可以为空
+func (r *Rule) ReplyFunc(responder func(request *http.Request) (*http.Response, error)) *Rule {
+	r.responder = responder
+	return r
+}
+
+// Calls 该规则匹配的次数
+func (r *Rule) Calls() int {
+	r.mock.lock.Lock()
+	defer r.mock.lock.Unlock()
+	return r.calls
+}
+
+func (r *Rule) match(request *http.Request, body []byte) bool {
+	if r.times >= 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != request.Method {
+		return false
+	}
+	if r.pattern != nil && !matchURL(r.pattern, request.URL) {
+		return false
+	}
+	for key, values := range r.headers {
+		for _, value := range values {
+			if !containsString(request.Header.Values(key), value) {
+				return false
+			}
+		}
+	}
+	if r.body != nil && !bytes.Equal(r.body, body) {
+		return false
+	}
+	if r.jsonBody != nil && !reflect.DeepEqual(r.jsonBody, normalizeJSON(body)) {
+		return false
+	}
+	for _, matcher := range r.matchers {
+		if !matcher(request, body) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) respond(request *http.Request, body []byte) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.responder != nil {
+		// 自定义响应时可以再次读取请求体
+		request = request.Clone(request.Context())
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		response, err := r.responder(request)
+		if response != nil && response.Request == nil {
+			response.Request = request
+		}
+		return response, err
+	}
+	return NewResponse(request, r.status, r.header.Clone(), r.response), nil
+}
+
+// NewResponse 创建响应, 用于ReplyFunc
+func NewResponse(request *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
+
+// matchURL pattern中的scheme/host为空时不比较, path以*结尾时按前缀匹配, query只要求包含pattern中的参数
+func matchURL(pattern *url.URL, u *url.URL) bool {
+	if pattern.Scheme != "" && !strings.EqualFold(pattern.Scheme, u.Scheme) {
+		return false
+	}
+	if pattern.Host != "" && !strings.EqualFold(pattern.Host, u.Host) {
+		return false
+	}
+	if prefix := strings.TrimSuffix(pattern.Path, "*"); prefix != pattern.Path {
+		if !strings.HasPrefix(u.Path, prefix) {
+			return false
+		}
+	} else if pattern.Path != "" && pattern.Path != u.Path {
+		return false
+	}
+	query := u.Query()
+	for key, values := range pattern.Query() {
+		for _, value := range values {
+			if !containsString(query[key], value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("toolstest: marshal %T: %v", v, err))
+	}
+	return data
+}
+
+// normalizeJSON 解析为interface{}, 用于比较, 不是json时返回nil
+func normalizeJSON(data []byte) interface{} {
+	var v interface{}
+	if jsoniter.Unmarshal(data, &v) != nil {
+		return nil
+	}
+	return v
+}
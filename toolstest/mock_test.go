@@ -0,0 +1,122 @@
+package toolstest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	tools "github.com/shenguanjiejie/go-tools/v3"
+)
+
+type user struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+var logNone = tools.NetLogLevelOption(tools.NetLogNone)
+
+func TestMockTransport(t *testing.T) {
+	mock := NewMockTransport().Install(t)
+	mock.On("GET", "https://api.example.com/users/1").ReplyJSON(http.StatusOK, &user{ID: 1, Title: "hello"}).Times(2)
+	mock.On("GET", "https://api.example.com/users/*?fields=title").Reply(http.StatusOK, `{"title":"prefix"}`)
+	mock.On("POST", "/users").WithHeader("X-Token", "abc").WithJSONBody(map[string]interface{}{"title": "new", "id": 2}).
+		Reply(http.StatusCreated, `{"id":2}`).ReplyHeader("Location", "/users/2")
+	networkErr := errors.New("connection reset")
+	mock.On("DELETE", "").ReplyError(networkErr)
+
+	for i := 0; i < 2; i++ {
+		result := new(user)
+		if err := tools.Get("https://api.example.com/users/1", nil, result, logNone); err != nil || result.Title != "hello" {
+			t.Error(err, result)
+		}
+	}
+	// 超过Times后不再匹配
+	if err := tools.Get("https://api.example.com/users/1", nil, new(user), logNone); err == nil {
+		t.Error("expect no mock error")
+	}
+
+	result := new(user)
+	if err := tools.Get("https://api.example.com/users/9", url.Values{"fields": {"title"}, "x": {"1"}}, result, logNone); err != nil || result.Title != "prefix" {
+		t.Error(err, result)
+	}
+
+	response := new(http.Response)
+	err := tools.Post("https://api.example.com/users", &user{ID: 2, Title: "new"}, response, logNone,
+		tools.NetHeader(http.Header{"X-Token": {"abc"}}))
+	if err != nil || response.StatusCode != http.StatusCreated || response.Header.Get("Location") != "/users/2" {
+		t.Fatal(err, response)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != `{"id":2}` {
+		t.Error(string(body))
+	}
+	// 请求头不匹配
+	if err = tools.Post("https://api.example.com/users", &user{ID: 2, Title: "new"}, nil, logNone); err == nil {
+		t.Error("expect no mock error")
+	}
+
+	if err = tools.Delete("https://api.example.com/users/1", nil, nil, logNone); !errors.Is(err, networkErr) {
+		t.Error(err)
+	}
+	if mock.Calls() != 5 {
+		t.Error(mock.Calls())
+	}
+
+	// 两个未匹配的请求
+	recorder := &testRecorder{TB: t}
+	mock.AssertExpectations(recorder)
+	if len(recorder.errors) != 2 || !strings.Contains(recorder.errors[0], "unmatched request GET https://api.example.com/users/1") {
+		t.Error(recorder.errors)
+	}
+}
+
+func TestMockTransportReplyFunc(t *testing.T) {
+	mock := NewMockTransport()
+	rule := mock.On("", "").WithMatcher(func(request *http.Request, body []byte) bool {
+		return strings.Contains(string(body), "ping")
+	}).ReplyFunc(func(request *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(request.Body)
+		return NewResponse(nil, http.StatusOK, nil, []byte(strings.Replace(string(body), "ping", "pong", 1))), nil
+	}).Once()
+
+	// 单次请求使用mock的client, 不影响默认的Transport
+	client := &http.Client{Transport: mock}
+	var result string
+	if err := tools.Put("https://example.com", "ping", &result, logNone, tools.NetHTTPClient(client)); err != nil || result != "pong" {
+		t.Error(err, result)
+	}
+	if rule.Calls() != 1 {
+		t.Error(rule.Calls())
+	}
+	mock.AssertExpectations(t)
+}
+
+// testRecorder 记录AssertExpectations的错误, 不让测试失败
+type testRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *testRecorder) Helper() {}
+
+func (r *testRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestInstallRestoresPrevious(t *testing.T) {
+	outer := NewMockTransport()
+	Install(t, outer)
+	t.Run("inner", func(t *testing.T) {
+		inner := NewMockTransport().Install(t)
+		if tools.GetTransport() != inner {
+			t.Error(tools.GetTransport())
+		}
+	})
+	if tools.GetTransport() != outer {
+		t.Error(tools.GetTransport())
+	}
+}
@@ -0,0 +1,270 @@
+package toolstest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	tools "github.com/shenguanjiejie/go-tools/v3"
+)
+
+// RecorderMode 录制/回放模式
+type RecorderMode int
+
+const (
+	ModeReplay         RecorderMode = iota // 只回放, 没有匹配的录制时请求返回错误, 不会发出真实请求
+	ModeRecord                             // 发出真实请求并录制, 覆盖已有的cassette
+	ModeReplayOrRecord                     // 有匹配的录制时回放, 否则发出真实请求并追加到cassette
+)
+
+// RecordEnv 设置了该环境变量时, Record使用ModeRecord, 否则使用ModeReplay, eg: TOOLSTEST_RECORD=1 go test ./...
+const RecordEnv = "TOOLSTEST_RECORD"
+
+// Cassette 录制的请求和响应, 以json格式保存
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction 一次请求和响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest 录制的请求
+type RecordedRequest struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"` // 不是utf8时Body为base64编码
+}
+
+// RecordedResponse 录制的响应
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"`
+}
+
+// RecorderOptionFunc Recorder配置
+type RecorderOptionFunc func(o *recorderOptions)
+
+type recorderOptions struct {
+	Transport http.RoundTripper                                                        // 发出真实请求使用的Transport, default: http.DefaultTransport
+	Filters   []func(interaction *Interaction)                                         // 保存前处理录制的内容, 如删除敏感信息
+	Matcher   func(request *http.Request, body []byte, recorded *RecordedRequest) bool // default: method/url/body相同
+}
+
+// RecorderTransport 发出真实请求使用的Transport
+func RecorderTransport(roundTripper http.RoundTripper) RecorderOptionFunc {
+	return func(o *recorderOptions) {
+		o.Transport = roundTripper
+	}
+}
+
+// RecorderFilter 录制后保存前处理录制的内容, 如删除敏感信息, 默认会删除Authorization/Proxy-Authorization/Cookie/Set-Cookie
+func RecorderFilter(filter func(interaction *Interaction)) RecorderOptionFunc {
+	return func(o *recorderOptions) {
+		o.Filters = append(o.Filters, filter)
+	}
+}
+
+// RecorderMatcher 回放时判断请求与录制的请求是否匹配, body为请求体
+func RecorderMatcher(matcher func(request *http.Request, body []byte, recorded *RecordedRequest) bool) RecorderOptionFunc {
+	return func(o *recorderOptions) {
+		o.Matcher = matcher
+	}
+}
+
+// Recorder 录制/回放请求的http.RoundTripper, 并发安全.
+// 回放时按顺序使用第一个未使用过的匹配的录制, 都使用过时使用最后一个匹配的录制
+type Recorder struct {
+	path    string
+	mode    RecorderMode
+	options recorderOptions
+
+	lock     sync.Mutex
+	cassette *Cassette
+	used     []bool
+	changed  bool
+}
+
+// NewRecorder 创建Recorder, path为cassette文件路径, 回放模式下文件不存在时返回错误
+func NewRecorder(path string, mode RecorderMode, options ...RecorderOptionFunc) (*Recorder, error) {
+	o := recorderOptions{Transport: http.DefaultTransport, Matcher: matchRecordedRequest}
+	o.Filters = append(o.Filters, removeSensitiveHeaders)
+	for _, option := range options {
+		option(&o)
+	}
+	recorder := &Recorder{path: path, mode: mode, options: o, cassette: new(Cassette)}
+	if mode != ModeRecord {
+		cassette, err := tools.LoadJSON[*Cassette](path)
+		switch {
+		case err == nil:
+			recorder.cassette = cassette
+		case mode == ModeReplay || !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		recorder.used = make([]bool, len(recorder.cassette.Interactions))
+	}
+	return recorder, nil
+}
+
+// Record 创建Recorder并通过Install设置为tools的默认Transport, 测试结束后保存cassette.
+// 设置了环境变量TOOLSTEST_RECORD时重新录制, 否则只回放
+func Record(t testing.TB, path string, options ...RecorderOptionFunc) *Recorder {
+	t.Helper()
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+	recorder, err := NewRecorder(path, mode, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Install(t, recorder)
+	t.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return recorder
+}
+
+// RoundTrip http.RoundTripper
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+	}
+
+	if r.mode != ModeRecord {
+		if interaction := r.find(request, body); interaction != nil {
+			return replay(request, interaction)
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("toolstest: no recorded interaction for %s %s in %s", request.Method, request.URL, r.path)
+		}
+	}
+	return r.record(request, body)
+}
+
+// Save 录制模式下有新的录制时保存cassette, 会自动创建目录
+func (r *Recorder) Save() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.changed {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	if err := tools.SaveJSON(r.path, r.cassette, "  "); err != nil {
+		return err
+	}
+	r.changed = false
+	return nil
+}
+
+func (r *Recorder) find(request *http.Request, body []byte) *Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var last *Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if !r.options.Matcher(request, body, &interaction.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction
+		}
+		last = interaction
+	}
+	return last
+}
+
+func (r *Recorder) record(request *http.Request, body []byte) (*http.Response, error) {
+	outgoing := request.Clone(request.Context())
+	if request.Body != nil {
+		outgoing.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	response, err := r.options.Transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := &Interaction{
+		Request:  RecordedRequest{Method: request.Method, URL: request.URL.String(), Header: request.Header.Clone()},
+		Response: RecordedResponse{StatusCode: response.StatusCode, Header: response.Header.Clone()},
+	}
+	interaction.Request.Body, interaction.Request.BodyBase64 = encodeBody(body)
+	interaction.Response.Body, interaction.Response.BodyBase64 = encodeBody(responseBody)
+	for _, filter := range r.options.Filters {
+		filter(interaction)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.changed = true
+	return response, nil
+}
+
+func replay(request *http.Request, interaction *Interaction) (*http.Response, error) {
+	body, err := decodeBody(interaction.Response.Body, interaction.Response.BodyBase64)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(request, interaction.Response.StatusCode, interaction.Response.Header.Clone(), body), nil
+}
+
+// matchRecordedRequest method/url/body相同时匹配
+func matchRecordedRequest(request *http.Request, body []byte, recorded *RecordedRequest) bool {
+	if request.Method != recorded.Method || request.URL.String() != recorded.URL {
+		return false
+	}
+	recordedBody, err := decodeBody(recorded.Body, recorded.BodyBase64)
+	return err == nil && bytes.Equal(body, recordedBody)
+}
+
+func removeSensitiveHeaders(interaction *Interaction) {
+	for _, key := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		interaction.Request.Header.Del(key)
+	}
+	interaction.Response.Header.Del("Set-Cookie")
+}
+
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+func decodeBody(body string, isBase64 bool) ([]byte, error) {
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
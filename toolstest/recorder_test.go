@@ -0,0 +1,98 @@
+package toolstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tools "github.com/shenguanjiejie/go-tools/v3"
+)
+
+func TestRecorder(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		fmt.Fprintf(w, `{"id":%d,"title":"%s"}`, n, r.URL.Query().Get("title"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "users.json")
+	get := func(recorder *Recorder, title string) (*user, error) {
+		result := new(user)
+		err := tools.Get(server.URL+"/users?title="+title, nil, result, logNone,
+			tools.NetHTTPClient(&http.Client{Transport: recorder}), tools.NetHeader(http.Header{"Authorization": {"Bearer token"}}))
+		return result, err
+	}
+
+	recorder, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"a", "a", "b"} {
+		if _, err = get(recorder, title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "Bearer") {
+		t.Error(string(data))
+	}
+
+	// 回放不会发出真实请求, 相同的请求按录制顺序返回, 用完后重复最后一个
+	recorder, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, title := range []string{"b", "a", "a", "a"} {
+		result, err := get(recorder, title)
+		if err != nil || result.Title != title {
+			t.Fatal(err, result)
+		}
+		ids = append(ids, result.ID)
+	}
+	if fmt.Sprint(ids) != "[3 1 2 2]" || atomic.LoadInt32(&requests) != 3 {
+		t.Error(ids, requests)
+	}
+	if _, err = get(recorder, "c"); err == nil {
+		t.Error("expect no recorded interaction error")
+	}
+
+	// 没有录制的请求会追加
+	recorder, err = NewRecorder(path, ModeReplayOrRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := get(recorder, "c"); err != nil || result.ID != 4 {
+		t.Error(err, result)
+	}
+	recorder.Save()
+	cassette, err := tools.LoadJSON[*Cassette](path)
+	if err != nil || len(cassette.Interactions) != 4 {
+		t.Error(err, cassette)
+	}
+
+	if _, err = NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); !os.IsNotExist(err) {
+		t.Error(err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join("testdata", "record.json")
+	t.Setenv(RecordEnv, "")
+	Record(t, path)
+	result := new(user)
+	if err := tools.Get("https://jsonplaceholder.typicode.com/posts/1", nil, result, logNone); err != nil || result.ID != 1 {
+		t.Error(err, result)
+	}
+}
@@ -0,0 +1,732 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket消息类型(opcode)
+const (
+	WSTextMessage   = 1
+	WSBinaryMessage = 2
+
+	wsContinuation = 0
+	wsClose        = 8
+	wsPing         = 9
+	wsPong         = 10
+)
+
+// WebSocket关闭状态码
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseNoStatus        = 1005
+	WSCloseMessageTooLarge = 1009
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWebSocketClosed 调用了Close, 或dial时传入的ctx已取消
+var ErrWebSocketClosed = errors.New("websocket: closed")
+
+// WSCloseError 收到了服务端的关闭帧
+type WSCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("websocket: closed by peer, code %d %s", e.Code, e.Reason)
+}
+
+// WSHandshakeError 握手失败, 服务端没有返回101或Sec-WebSocket-Accept不正确
+type WSHandshakeError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *WSHandshakeError) Error() string {
+	return fmt.Sprintf("websocket: handshake failed, status %d: %s", e.StatusCode, e.Reason)
+}
+
+// retryable 5xx和429可以重连
+func (e *WSHandshakeError) retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+// WSMessage 收到的消息
+type WSMessage struct {
+	Type int // WSTextMessage / WSBinaryMessage
+	Data []byte
+}
+
+// WSOptionFunc DialWebSocket配置
+type WSOptionFunc func(o *wsOptions)
+
+type wsOptions struct {
+	NetOptions     []NetOptionFunc
+	Subprotocols   []string
+	PingInterval   time.Duration // 发送ping的间隔, 小于等于0不发送, default: 30s
+	PongTimeout    time.Duration // 发送ping后超过该时间没有收到任何数据则认为连接已断开, default: 10s
+	Reconnect      bool          // 连接断开后自动重连, default: true
+	Backoff        time.Duration // 重连的初始间隔, 连续失败时翻倍, default: 1s
+	MaxBackoff     time.Duration // default: 30s
+	MaxRetries     int           // 连续重连失败的最大次数, 小于0不限制, default: -1
+	MaxMessageSize int64         // 收到的单条消息的最大字节数, 小于等于0时不限制消息, 但单个帧不超过wsMaxFrameSize, default: 16MB
+	BufferSize     int           // 收到的消息的缓冲数量, default: 16
+	OnConnect      func(ws *WebSocket)
+}
+
+// WSNetOptions 握手时使用的配置, 如请求头/鉴权/client/日志等, Timeout只作用于握手.
+// 日志级别同时作用于消息: NetLogParams打印发送的消息, NetLogResponse打印收到的消息
+func WSNetOptions(options ...NetOptionFunc) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.NetOptions = append(o.NetOptions, options...)
+	}
+}
+
+// WSSubprotocols Sec-WebSocket-Protocol
+func WSSubprotocols(protocols ...string) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.Subprotocols = append(o.Subprotocols, protocols...)
+	}
+}
+
+// WSKeepAlive ping间隔和等待pong的超时时间, interval小于等于0时不发送ping
+func WSKeepAlive(interval time.Duration, pongTimeout time.Duration) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.PingInterval = interval
+		o.PongTimeout = pongTimeout
+	}
+}
+
+// WSReconnect 是否自动重连, 以及重连的初始间隔/最大间隔/连续失败的最大次数(小于0不限制)
+func WSReconnect(enabled bool, backoff time.Duration, maxBackoff time.Duration, maxRetries int) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.Reconnect = enabled
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+		o.MaxRetries = maxRetries
+	}
+}
+
+// WSMaxMessageSize 收到的单条消息的最大字节数, 超过时断开连接
+func WSMaxMessageSize(size int64) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.MaxMessageSize = size
+	}
+}
+
+// WSBufferSize 收到的消息的缓冲数量, 缓冲满了之后会暂停读取, 直到调用Receive
+func WSBufferSize(size int) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.BufferSize = size
+	}
+}
+
+// WSOnConnect 每次连接(包括重连)成功后调用, 可用于重新订阅, 在读取消息的协程中调用, 不要阻塞太久
+func WSOnConnect(fn func(ws *WebSocket)) WSOptionFunc {
+	return func(o *wsOptions) {
+		o.OnConnect = fn
+	}
+}
+
+// WebSocket RFC 6455客户端, 并发安全, 断开后自动重连(重连期间Send会等待连接恢复)
+type WebSocket struct {
+	url     string
+	options wsOptions
+	config  *httpConfig // 用于打印消息日志
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock        sync.Mutex
+	conn        *wsConn
+	ready       chan struct{} // 连接可用时关闭
+	subprotocol string
+
+	incoming chan WSMessage
+	done     chan struct{} // 不再重连后关闭
+	err      error
+}
+
+// DialWebSocket 建立WebSocket连接, url支持ws/wss/http/https, 首次握手失败直接返回错误.
+// ctx取消或调用Close后关闭连接并停止重连
+func DialWebSocket(ctx context.Context, url string, options ...WSOptionFunc) (*WebSocket, error) {
+	o := wsOptions{
+		PingInterval:   30 * time.Second,
+		PongTimeout:    10 * time.Second,
+		Reconnect:      true,
+		Backoff:        time.Second,
+		MaxBackoff:     30 * time.Second,
+		MaxRetries:     -1,
+		MaxMessageSize: 16 << 20,
+		BufferSize:     16,
+	}
+	for _, option := range options {
+		option(&o)
+	}
+
+	ws := &WebSocket{
+		url:      url,
+		options:  o,
+		config:   configWithOptions(o.NetOptions...),
+		ready:    make(chan struct{}),
+		incoming: make(chan WSMessage, o.BufferSize),
+		done:     make(chan struct{}),
+	}
+	if ws.config.NetLogLevel == NetLogNil {
+		ws.config.NetLogLevel = NetLogAll
+	}
+	ws.ctx, ws.cancel = context.WithCancel(ctx)
+	conn, err := ws.dial()
+	if err != nil {
+		ws.cancel()
+		return nil, err
+	}
+	go ws.run(conn)
+	return ws, nil
+}
+
+// Subprotocol 服务端选择的子协议
+func (ws *WebSocket) Subprotocol() string {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	return ws.subprotocol
+}
+
+// Done 不再重连(调用了Close, 或重连失败)后关闭, 之后Err返回原因
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Err Done关闭后返回结束的原因
+func (ws *WebSocket) Err() error {
+	select {
+	case <-ws.done:
+		return ws.err
+	default:
+		return nil
+	}
+}
+
+// Send 发送消息, 正在重连时等待连接恢复
+func (ws *WebSocket) Send(ctx context.Context, messageType int, data []byte) error {
+	return ws.send(ctx, messageType, data)
+}
+
+// SendText 发送文本消息
+func (ws *WebSocket) SendText(ctx context.Context, text string) error {
+	return ws.send(ctx, WSTextMessage, []byte(text))
+}
+
+// SendJSON 使用json codec序列化v, 以文本消息发送
+func (ws *WebSocket) SendJSON(ctx context.Context, v interface{}) error {
+	data, err := codecOrJSON(CodecJSON).Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.send(ctx, WSTextMessage, data)
+}
+
+func (ws *WebSocket) send(ctx context.Context, messageType int, data []byte) error {
+	if messageType != WSTextMessage && messageType != WSBinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	for {
+		ws.lock.Lock()
+		conn, ready := ws.conn, ws.ready
+		ws.lock.Unlock()
+		if conn != nil {
+			// 用户代码 -> Send/SendText/SendJSON -> send -> logMessage
+			ws.logMessage(NetLogParams, messageType, data, 3)
+			return conn.writeFrame(byte(messageType), data)
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ws.done:
+			return ws.err
+		}
+	}
+}
+
+// Receive 接收一条消息, 不再重连后返回结束的原因
+func (ws *WebSocket) Receive(ctx context.Context) (WSMessage, error) {
+	return ws.receive(ctx)
+}
+
+// ReceiveJSON 接收一条消息并使用json codec反序列化到v
+func (ws *WebSocket) ReceiveJSON(ctx context.Context, v interface{}) error {
+	message, err := ws.receive(ctx)
+	if err != nil {
+		return err
+	}
+	return codecOrJSON(CodecJSON).Unmarshal(message.Data, v)
+}
+
+func (ws *WebSocket) receive(ctx context.Context) (message WSMessage, err error) {
+	// 优先返回已收到的消息
+	select {
+	case message = <-ws.incoming:
+	default:
+		select {
+		case message = <-ws.incoming:
+		case <-ctx.Done():
+			return message, ctx.Err()
+		case <-ws.done:
+			select {
+			case message = <-ws.incoming:
+			default:
+				return message, ws.err
+			}
+		}
+	}
+	// 用户代码 -> Receive/ReceiveJSON -> receive -> logMessage
+	ws.logMessage(NetLogResponse, message.Type, message.Data, 3)
+	return message, nil
+}
+
+// Close 发送关闭帧并关闭连接, 停止重连
+func (ws *WebSocket) Close() error {
+	ws.cancel()
+	ws.lock.Lock()
+	conn := ws.conn
+	ws.lock.Unlock()
+	if conn != nil {
+		conn.writeClose(WSCloseNormal, "")
+		conn.close()
+	}
+	<-ws.done
+	return nil
+}
+
+func (ws *WebSocket) logMessage(level NetLogLevel, messageType int, data []byte, skip int) {
+	if ws.config.NetLogLevel&level == 0 {
+		return
+	}
+	contentType := "text/plain"
+	if messageType == WSBinaryMessage {
+		contentType = "application/octet-stream"
+	}
+	Logln(LogCallerSkip(ws.config.LogCallerSkip+skip), LogLineSkip(ws.config.LogLineSkip+skip), logBody(data, contentType, ws.config.MaxLogBytes))
+}
+
+// run 读取消息, 断开后重连, 直到Close或无法重连
+func (ws *WebSocket) run(conn *wsConn) {
+	var err error
+	for {
+		err = ws.serve(conn)
+		ws.setConn(nil)
+		if ws.ctx.Err() != nil {
+			err = ErrWebSocketClosed
+			break
+		}
+		if !ws.options.Reconnect {
+			break
+		}
+		if conn, err = ws.reconnect(); err != nil {
+			break
+		}
+	}
+	ws.err = err
+	close(ws.done)
+}
+
+func (ws *WebSocket) reconnect() (*wsConn, error) {
+	for failures := 1; ; failures++ {
+		timer := time.NewTimer(backoffDelay(ws.options.Backoff, ws.options.MaxBackoff, failures))
+		select {
+		case <-ws.ctx.Done():
+			timer.Stop()
+			return nil, ErrWebSocketClosed
+		case <-timer.C:
+		}
+
+		conn, err := ws.dial()
+		if err == nil {
+			return conn, nil
+		}
+		if ws.ctx.Err() != nil {
+			return nil, ErrWebSocketClosed
+		}
+		var handshakeErr *WSHandshakeError
+		if errors.As(err, &handshakeErr) && !handshakeErr.retryable() {
+			return nil, err
+		}
+		if ws.options.MaxRetries >= 0 && failures >= ws.options.MaxRetries {
+			return nil, err
+		}
+	}
+}
+
+func (ws *WebSocket) setConn(conn *wsConn) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	ws.conn = conn
+	if conn != nil {
+		close(ws.ready)
+	} else {
+		ws.ready = make(chan struct{})
+	}
+}
+
+// serve 读取conn的消息直到连接断开
+func (ws *WebSocket) serve(conn *wsConn) error {
+	ws.setConn(conn)
+	stop := make(chan struct{})
+	defer close(stop)
+	go ws.keepAlive(conn, stop)
+	if ws.options.OnConnect != nil {
+		ws.options.OnConnect(ws)
+	}
+
+	for {
+		messageType, data, err := conn.readMessage()
+		if err != nil {
+			conn.close()
+			return err
+		}
+		atomic.StoreInt32(&conn.delivering, 1)
+		select {
+		case ws.incoming <- WSMessage{Type: int(messageType), Data: data}:
+			atomic.StoreInt32(&conn.delivering, 0)
+			conn.touch()
+		case <-ws.ctx.Done():
+			conn.close()
+			return ErrWebSocketClosed
+		}
+	}
+}
+
+// keepAlive 定时发送ping, 超时没有收到数据时关闭连接, 触发重连; ctx取消时关闭连接
+func (ws *WebSocket) keepAlive(conn *wsConn, stop chan struct{}) {
+	var tick <-chan time.Time
+	if ws.options.PingInterval > 0 {
+		ticker := time.NewTicker(ws.options.PingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ws.ctx.Done():
+			conn.writeClose(WSCloseGoingAway, "")
+			conn.close()
+			return
+		case <-tick:
+		}
+		// 等待Receive时不会读取数据, 不算超时
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&conn.lastRead)))
+		if atomic.LoadInt32(&conn.delivering) == 0 && idle > ws.options.PingInterval+ws.options.PongTimeout {
+			conn.close()
+			return
+		}
+		if err := conn.writeFrame(wsPing, nil); err != nil {
+			conn.close()
+			return
+		}
+	}
+}
+
+// dial 握手, 通过request发出, 支持NetOptionFunc的所有配置
+func (ws *WebSocket) dial() (*wsConn, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	secKey := base64.StdEncoding.EncodeToString(key)
+
+	options := append([]NetOptionFunc{LogCallerSkipOption(-2), LogLineSkipOption(-2)}, ws.options.NetOptions...)
+	config := configWithOptions(options...)
+	ctx := ws.ctx
+	// http.Client的Timeout会包装响应体, 导致无法获取连接, 改为只作用于握手的context
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+		config.Timeout = 0
+	}
+	config.Context = ctx
+	config.Method = http.MethodGet
+	config.URL = wsHTTPURL(ws.url)
+	config.CacheMode = CacheBypass
+	config.MaxResponseSize = 0
+	config.NetLogLevel &^= NetLogDump
+	header := make(http.Header)
+	if config.Header != nil {
+		header = config.Header.Clone()
+	}
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", "websocket")
+	header.Set("Sec-WebSocket-Version", "13")
+	header.Set("Sec-WebSocket-Key", secKey)
+	if len(ws.options.Subprotocols) > 0 {
+		header.Set("Sec-WebSocket-Protocol", strings.Join(ws.options.Subprotocols, ", "))
+	}
+	config.Header = header
+
+	response := new(http.Response)
+	if err := request(response, config); err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		return nil, &WSHandshakeError{StatusCode: response.StatusCode, Reason: string(body)}
+	}
+	rwc, ok := response.Body.(io.ReadWriteCloser)
+	if !ok {
+		response.Body.Close()
+		return nil, &WSHandshakeError{StatusCode: response.StatusCode, Reason: "response body is not writable"}
+	}
+	if !strings.EqualFold(response.Header.Get("Upgrade"), "websocket") ||
+		response.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(secKey) {
+		rwc.Close()
+		return nil, &WSHandshakeError{StatusCode: response.StatusCode, Reason: "invalid Upgrade or Sec-WebSocket-Accept"}
+	}
+
+	ws.lock.Lock()
+	ws.subprotocol = response.Header.Get("Sec-WebSocket-Protocol")
+	ws.lock.Unlock()
+	return newWSConn(rwc, bufio.NewReader(rwc), true, ws.options.MaxMessageSize), nil
+}
+
+func wsHTTPURL(url string) string {
+	switch {
+	case strings.HasPrefix(url, "ws://"):
+		return "http://" + url[len("ws://"):]
+	case strings.HasPrefix(url, "wss://"):
+		return "https://" + url[len("wss://"):]
+	}
+	return url
+}
+
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn 帧的读写, client为true时发送的帧需要掩码
+type wsConn struct {
+	rwc            io.ReadWriteCloser
+	reader         *bufio.Reader
+	client         bool
+	maxMessageSize int64
+
+	writeLock  sync.Mutex
+	closeOnce  sync.Once
+	lastRead   int64 // unix纳秒
+	delivering int32
+}
+
+// wsMaxFrameSize 没有设置MaxMessageSize时单个帧的最大字节数, 避免按对方发送的长度分配过大的内存
+const wsMaxFrameSize = 256 << 20
+
+func newWSConn(rwc io.ReadWriteCloser, reader *bufio.Reader, client bool, maxMessageSize int64) *wsConn {
+	conn := &wsConn{rwc: rwc, reader: reader, client: client, maxMessageSize: maxMessageSize}
+	conn.touch()
+	return conn
+}
+
+func (c *wsConn) touch() {
+	atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+}
+
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		c.rwc.Close()
+	})
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		var extended [2]byte
+		binary.BigEndian.PutUint16(extended[:], uint16(length))
+		frame = append(append(frame, maskBit|126), extended[:]...)
+	default:
+		var extended [8]byte
+		binary.BigEndian.PutUint64(extended[:], uint64(length))
+		frame = append(append(frame, maskBit|127), extended[:]...)
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := c.rwc.Write(frame)
+	return err
+}
+
+func (c *wsConn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return c.writeFrame(wsClose, append(payload, reason...))
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		err = c.protocolError("unexpected rsv bits")
+		return
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+		if length&(1<<63) != 0 {
+			err = c.protocolError("invalid payload length")
+			return
+		}
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		err = c.protocolError("invalid control frame")
+		return
+	}
+	maxFrameSize := uint64(wsMaxFrameSize)
+	if c.maxMessageSize > 0 {
+		maxFrameSize = uint64(c.maxMessageSize)
+	}
+	if length > maxFrameSize {
+		c.writeClose(WSCloseMessageTooLarge, "")
+		err = fmt.Errorf("websocket: message too large, %d bytes", length)
+		return
+	}
+
+	var mask [4]byte
+	masked := header[1]&0x80 != 0
+	// RFC 6455 5.1: 服务端发送的帧不能有掩码, 客户端发送的帧必须有掩码
+	if masked == c.client {
+		err = c.protocolError("unexpected frame mask")
+		return
+	}
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// readMessage 读取一条完整的消息(合并分片), 自动回复ping和close
+func (c *wsConn) readMessage() (messageType byte, message []byte, err error) {
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		c.touch()
+
+		switch opcode {
+		case wsPing:
+			if err = c.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			closeErr := &WSCloseError{Code: WSCloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			code := closeErr.Code
+			if code == WSCloseNoStatus {
+				code = WSCloseNormal
+			}
+			c.writeClose(code, "")
+			return 0, nil, closeErr
+		case wsContinuation:
+			if messageType == 0 {
+				return 0, nil, c.protocolError("unexpected continuation frame")
+			}
+			message = append(message, payload...)
+		case WSTextMessage, WSBinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.protocolError("expect continuation frame")
+			}
+			messageType, message = opcode, payload
+		default:
+			return 0, nil, c.protocolError(fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if c.maxMessageSize > 0 && int64(len(message)) > c.maxMessageSize {
+			c.writeClose(WSCloseMessageTooLarge, "")
+			return 0, nil, fmt.Errorf("websocket: message too large, %d bytes", len(message))
+		}
+		if fin {
+			if messageType == WSTextMessage && !utf8.Valid(message) {
+				return 0, nil, c.protocolError("invalid utf-8 text message")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+func (c *wsConn) protocolError(reason string) error {
+	c.writeClose(WSCloseProtocolError, reason)
+	return errors.New("websocket: protocol error, " + reason)
+}
@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wsTestServer 测试用的服务端, handler返回后关闭连接
+func wsTestServer(t *testing.T, handler func(conn *wsConn, r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" || r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		netConn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n"
+		if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+			response += "Sec-WebSocket-Protocol: " + strings.Split(protocol, ",")[0] + "\r\n"
+		}
+		rw.WriteString(response + "\r\n")
+		rw.Flush()
+		conn := newWSConn(netConn, rw.Reader, false, 0)
+		defer conn.close()
+		handler(conn, r)
+	}))
+}
+
+// wsEcho 原样返回收到的消息
+func wsEcho(conn *wsConn, r *http.Request) {
+	for {
+		messageType, message, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+		if conn.writeFrame(messageType, message) != nil {
+			return
+		}
+	}
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		if r.Header.Get("X-Token") != "abc" {
+			conn.writeClose(WSCloseProtocolError, "missing token")
+			return
+		}
+		wsEcho(conn, r)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	ws, err := DialWebSocket(ctx, wsURL(server), WSSubprotocols("chat", "json"),
+		WSNetOptions(NetHeader(map[string][]string{"X-Token": {"abc"}}), NetLogLevelOption(NetLogNone), Timeout(time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if ws.Subprotocol() != "chat" {
+		t.Error(ws.Subprotocol())
+	}
+
+	if err = ws.SendJSON(ctx, &User{ID: 1, Title: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	user := new(User)
+	if err = ws.ReceiveJSON(ctx, user); err != nil || user.ID != 1 || user.Title != "hello" {
+		t.Error(err, user)
+	}
+
+	// 需要64位长度的消息
+	large := strings.Repeat("你好", 40000)
+	ws.SendText(ctx, large)
+	message, err := ws.Receive(ctx)
+	if err != nil || message.Type != WSTextMessage || string(message.Data) != large {
+		t.Error(err, message.Type, len(message.Data))
+	}
+
+	ws.Send(ctx, WSBinaryMessage, []byte{0, 1, 2})
+	if message, err = ws.Receive(ctx); err != nil || message.Type != WSBinaryMessage || len(message.Data) != 3 {
+		t.Error(err, message)
+	}
+	if err = ws.Send(ctx, wsPing, nil); err == nil {
+		t.Error("expect invalid message type")
+	}
+}
+
+func TestWebSocketFragments(t *testing.T) {
+	pong := make(chan string, 1)
+	server := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		// "hel" + ping + "lo"
+		conn.rwc.Write([]byte{0x01, 3, 'h', 'e', 'l', 0x89, 2, 'h', 'i', 0x80, 2, 'l', 'o'})
+		_, opcode, payload, err := conn.readFrame()
+		if err == nil && opcode == wsPong {
+			pong <- string(payload)
+		}
+		wsEcho(conn, r)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	ws, err := DialWebSocket(ctx, wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	message, err := ws.Receive(ctx)
+	if err != nil || string(message.Data) != "hello" {
+		t.Error(err, string(message.Data))
+	}
+	if payload := <-pong; payload != "hi" {
+		t.Error(payload)
+	}
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	var connections int32
+	server := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			conn.writeFrame(WSTextMessage, []byte("first"))
+			return
+		}
+		wsEcho(conn, r)
+	})
+	defer server.Close()
+
+	var connects int32
+	ctx := context.Background()
+	ws, err := DialWebSocket(ctx, wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)),
+		WSReconnect(true, 10*time.Millisecond, 50*time.Millisecond, 3),
+		WSOnConnect(func(ws *WebSocket) {
+			atomic.AddInt32(&connects, 1)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if message, err := ws.Receive(ctx); err != nil || string(message.Data) != "first" {
+		t.Fatal(err, message)
+	}
+	// 断开后Send会等待重连
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err = ws.SendText(timeout, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if message, err := ws.Receive(timeout); err != nil || string(message.Data) != "second" {
+		t.Error(err, message)
+	}
+	if atomic.LoadInt32(&connections) != 2 || atomic.LoadInt32(&connects) != 2 {
+		t.Error(connections, connects)
+	}
+}
+
+func TestWebSocketKeepAlive(t *testing.T) {
+	var connections int32
+	server := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// 不读取数据, 不回复pong
+			time.Sleep(time.Second)
+			return
+		}
+		wsEcho(conn, r)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	ws, err := DialWebSocket(ctx, wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)),
+		WSKeepAlive(20*time.Millisecond, 20*time.Millisecond), WSReconnect(true, time.Millisecond, time.Millisecond, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// 第一个连接没有响应, 超时后重连
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&connections) != 2 {
+		t.Fatal(connections)
+	}
+	// 第二个连接会回复pong, 不会断开
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&connections) != 2 {
+		t.Error(connections)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	server := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		if r.URL.Path == "/bye" {
+			conn.writeClose(WSCloseNormal, "bye")
+			conn.readMessage()
+			return
+		}
+		wsEcho(conn, r)
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	var handshakeErr *WSHandshakeError
+	if _, err := DialWebSocket(ctx, server.URL+"/forbidden", WSNetOptions(NetLogLevelOption(NetLogNone))); !errors.As(err, &handshakeErr) || handshakeErr.StatusCode != http.StatusForbidden {
+		t.Error(err)
+	}
+
+	// 不重连时返回服务端的关闭原因
+	ws, err := DialWebSocket(ctx, wsURL(server)+"/bye", WSNetOptions(NetLogLevelOption(NetLogNone)), WSReconnect(false, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closeErr *WSCloseError
+	if _, err = ws.Receive(ctx); !errors.As(err, &closeErr) || closeErr.Code != WSCloseNormal || closeErr.Reason != "bye" {
+		t.Error(err)
+	}
+	<-ws.Done()
+	if !errors.As(ws.Err(), &closeErr) {
+		t.Error(ws.Err())
+	}
+
+	ws, err = DialWebSocket(ctx, wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+	if _, err = ws.Receive(ctx); err != ErrWebSocketClosed {
+		t.Error(err)
+	}
+	if err = ws.SendText(ctx, "x"); err != ErrWebSocketClosed {
+		t.Error(err)
+	}
+
+	// ctx取消后关闭
+	cancelCtx, cancel := context.WithCancel(ctx)
+	ws, err = DialWebSocket(cancelCtx, wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-ws.Done():
+	case <-time.After(time.Second):
+		t.Error("websocket should be closed after ctx canceled")
+	}
+}
+
+func TestWebSocketMaxRetries(t *testing.T) {
+	upgrade := wsTestServer(t, func(conn *wsConn, r *http.Request) {
+		conn.writeFrame(WSTextMessage, []byte("hi"))
+	})
+	defer upgrade.Close()
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		upgrade.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ws, err := DialWebSocket(context.Background(), wsURL(server), WSNetOptions(NetLogLevelOption(NetLogNone)),
+		WSReconnect(true, time.Millisecond, time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	select {
+	case <-ws.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expect give up reconnecting")
+	}
+	// 第一次连接, 加上2次重连
+	var handshakeErr *WSHandshakeError
+	if !errors.As(ws.Err(), &handshakeErr) || atomic.LoadInt32(&attempts) != 3 {
+		t.Error(ws.Err(), attempts)
+	}
+}
+
+// wsFrameConn 从frame中读取, 丢弃写入的数据
+type wsFrameConn struct {
+	*bytes.Reader
+}
+
+func (c wsFrameConn) Write(p []byte) (int, error) { return len(p), nil }
+
+func (c wsFrameConn) Close() error { return nil }
+
+func TestWebSocketReadFrame(t *testing.T) {
+	for name, frame := range map[string][]byte{
+		"masked server frame": {0x81, 0x82, 1, 2, 3, 4, 'h' ^ 1, 'i' ^ 2},
+		"length high bit":     {0x82, 127, 0x80, 0, 0, 0, 0, 0, 0, 1},
+		"too large":           {0x82, 127, 0, 0, 0, 1, 0, 0, 0, 0},
+	} {
+		conn := newWSConn(wsFrameConn{bytes.NewReader(frame)}, bufio.NewReader(bytes.NewReader(frame)), true, 0)
+		if _, _, _, err := conn.readFrame(); err == nil {
+			t.Error(name)
+		}
+	}
+
+	// 服务端收到没有掩码的帧
+	frame := []byte{0x81, 0x02, 'h', 'i'}
+	conn := newWSConn(wsFrameConn{bytes.NewReader(frame)}, bufio.NewReader(bytes.NewReader(frame)), false, 0)
+	if _, _, _, err := conn.readFrame(); err == nil {
+		t.Error("expect unmasked client frame error")
+	}
+	conn = newWSConn(wsFrameConn{bytes.NewReader(frame)}, bufio.NewReader(bytes.NewReader(frame)), true, 0)
+	if _, _, payload, err := conn.readFrame(); err != nil || string(payload) != "hi" {
+		t.Error(err, string(payload))
+	}
+}